@@ -2,10 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/executors"
+	"go-Job-Scheduler/histories"
 	"go-Job-Scheduler/jobs"
 	"go-Job-Scheduler/schedulers"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type response struct {
@@ -53,12 +61,22 @@ func handleJobAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 检查执行器能否执行该任务，如 shell 命令是否在允许列表中、函数参数能否转换为声明的类型
+	err = executors.ValidateJob(j)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
 	err = scheduler.JobStore.AddJob(j)
 	if err != nil {
 		resp.Code = 1
 		resp.Message = err.Error()
 		return
 	}
+	// 唤醒调度器重新计算等待时间
+	scheduler.Wakeup()
 	resp.Message = "success"
 	return
 }
@@ -98,6 +116,9 @@ func handleJobDelete(w http.ResponseWriter, r *http.Request) {
 		resp.Message = err.Error()
 		return
 	}
+	// 取消该任务正在运行的实例
+	scheduler.Executor.Cancel(j.Id)
+	scheduler.Wakeup()
 	resp.Message = "success"
 	return
 }
@@ -132,18 +153,34 @@ func handleJobUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	jobOld := scheduler.JobStore.GetJobById(j.Id)
-	if jobOld == nil {
+	if jobOld == nil || jobOld.Id == "" {
 		resp.Code = 1
 		resp.Message = "error: no such a job"
 		return
 	}
 
+	if j.Shell != nil || j.OnSuccess != nil {
+		merged := *jobOld
+		if j.Shell != nil {
+			merged.Shell = j.Shell
+		}
+		if j.OnSuccess != nil {
+			merged.OnSuccess = j.OnSuccess
+		}
+		if err = executors.ValidateJob(merged); err != nil {
+			resp.Code = 1
+			resp.Message = err.Error()
+			return
+		}
+	}
+
 	err = scheduler.JobStore.UpdateJob(jobOld, j)
 	if err != nil {
 		resp.Code = 1
 		resp.Message = err.Error()
 		return
 	}
+	scheduler.Wakeup()
 	resp.Message = "success"
 	return
 }
@@ -197,3 +234,954 @@ func handleJobsList(w http.ResponseWriter, r *http.Request) {
 	resp.Data = scheduler.JobStore.GetAllJobs()
 	return
 }
+
+// route "/api/calendar/add"，添加日历api
+func handleCalendarAdd(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var c jobs.Calendar
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	err = scheduler.JobStore.AddCalendar(c)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	return
+}
+
+// route "/api/calendar/update"，修改日历api，整体替换同名日历，并重新计算使用该日历的任务的下次执行时间
+func handleCalendarUpdate(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var c jobs.Calendar
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	err = scheduler.JobStore.UpdateCalendar(c)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	// 使用该日历的任务的下次执行时间可能已改变
+	scheduler.Wakeup()
+	resp.Message = "success"
+	return
+}
+
+// route "/api/calendar/delete"，删除日历api
+func handleCalendarDelete(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var c jobs.Calendar
+	err := json.NewDecoder(r.Body).Decode(&c)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	err = scheduler.JobStore.RemoveCalendar(c.Name)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	return
+}
+
+// route "/api/calendar/?name=xxx"，查询日历api
+func handleCalendarRead(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	name := r.URL.Query().Get("name")
+	if strings.EqualFold(name, "") {
+		resp.Code = 1
+		resp.Message = "must supply a calendar name param"
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	calendar := scheduler.JobStore.GetCalendar(name)
+	if calendar == nil {
+		resp.Code = 1
+		resp.Message = "error: no such a calendar"
+		return
+	}
+	resp.Message = "success"
+	resp.Data = calendar
+	return
+}
+
+// route "/api/calendars"，所有日历列表api
+func handleCalendarsList(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	resp.Message = "success"
+	resp.Data = scheduler.JobStore.GetAllCalendars()
+	return
+}
+
+// route "/api/job/events?id=xxx"，任务执行事件api，如达到最大实例数被跳过、排队等
+func handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	id := r.URL.Query().Get("id")
+	if strings.EqualFold(id, "") {
+		resp.Code = 1
+		resp.Message = "must supply a job id param"
+		return
+	}
+
+	resp.Message = "success"
+	resp.Data = executors.Events(id)
+	return
+}
+
+// route "/api/workflow/add"，添加工作流api，返回生成 id 后的工作流
+func handleWorkflowAdd(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var wf jobs.Workflow
+	err := json.NewDecoder(r.Body).Decode(&wf)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	err = scheduler.JobStore.AddWorkflow(&wf)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = wf
+	return
+}
+
+// route "/api/workflow/delete"，删除工作流api
+func handleWorkflowDelete(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var wf jobs.Workflow
+	err := json.NewDecoder(r.Body).Decode(&wf)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	err = scheduler.JobStore.RemoveWorkflow(wf.Id)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	return
+}
+
+// route "/api/workflow/?id=xxx"，查询工作流api
+func handleWorkflowRead(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	id := r.URL.Query().Get("id")
+	if strings.EqualFold(id, "") {
+		resp.Code = 1
+		resp.Message = "must supply a workflow id param"
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	workflow := scheduler.JobStore.GetWorkflow(id)
+	if workflow == nil {
+		resp.Code = 1
+		resp.Message = "error: no such a workflow"
+		return
+	}
+	resp.Message = "success"
+	resp.Data = workflow
+	return
+}
+
+// route "/api/workflows"，查询所有工作流api
+func handleWorkflowsList(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	resp.Message = "success"
+	resp.Data = scheduler.JobStore.GetAllWorkflows()
+	return
+}
+
+// route "/api/workflow/run"，开始工作流的一次运行api，body 为 {"id": 工作流 id}
+func handleWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var wf jobs.Workflow
+	err := json.NewDecoder(r.Body).Decode(&wf)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	run, err := scheduler.StartWorkflow(wf.Id)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = run
+	return
+}
+
+// route "/api/workflow/cancel"，取消工作流运行api，body 为 {"id": 运行 id}
+func handleWorkflowCancel(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var run jobs.WorkflowRun
+	err := json.NewDecoder(r.Body).Decode(&run)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	cancelled, err := scheduler.CancelWorkflowRun(run.Id)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = cancelled
+	return
+}
+
+// route "/api/workflow/run/?id=xxx"，查询工作流运行状态api
+func handleWorkflowRunRead(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	id := r.URL.Query().Get("id")
+	if strings.EqualFold(id, "") {
+		resp.Code = 1
+		resp.Message = "must supply a workflow run id param"
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	run := scheduler.JobStore.GetWorkflowRun(id)
+	if run == nil {
+		resp.Code = 1
+		resp.Message = "error: no such a workflow run"
+		return
+	}
+	resp.Message = "success"
+	resp.Data = run
+	return
+}
+
+// route "/api/workflow/runs?id=xxx"，查询工作流的所有运行api，不传 id 时返回所有工作流的运行
+func handleWorkflowRunsList(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	resp.Message = "success"
+	resp.Data = scheduler.JobStore.GetWorkflowRuns(r.URL.Query().Get("id"))
+	return
+}
+
+// route "/api/deadletters?id=xxx"，查询重试次数用尽后仍失败的执行api，不传 id 时返回所有任务的死信
+func handleDeadLettersList(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	resp.Message = "success"
+	resp.Data = scheduler.JobStore.GetDeadLetters(r.URL.Query().Get("id"))
+	return
+}
+
+// route "/api/deadletter/?id=xxx"，查询死信api
+func handleDeadLetterRead(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	id := r.URL.Query().Get("id")
+	if strings.EqualFold(id, "") {
+		resp.Code = 1
+		resp.Message = "must supply a dead letter id param"
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	deadLetter := scheduler.JobStore.GetDeadLetter(id)
+	if deadLetter == nil {
+		resp.Code = 1
+		resp.Message = "error: no such a dead letter"
+		return
+	}
+	resp.Message = "success"
+	resp.Data = deadLetter
+	return
+}
+
+// route "/api/deadletter/replay"，立即重新执行死信中的任务api，body 为 {"id": 死信 id}
+func handleDeadLetterReplay(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var d jobs.DeadLetter
+	err := json.NewDecoder(r.Body).Decode(&d)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	job, err := scheduler.ReplayDeadLetter(d.Id)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = job
+	return
+}
+
+// route "/api/deadletter/delete"，删除死信api
+func handleDeadLetterDelete(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var d jobs.DeadLetter
+	err := json.NewDecoder(r.Body).Decode(&d)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		resp.Code = 1
+		resp.Message = "scheduler is not running"
+		return
+	}
+
+	err = scheduler.JobStore.RemoveDeadLetter(d.Id)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	return
+}
+
+// parseRunFilter 解析执行记录的查询参数: status 执行状态，from / to 开始时间范围(RFC3339 或 2006-01-02 15:04:05)，limit 最大条数
+func parseRunFilter(r *http.Request) (histories.Filter, error) {
+	query := r.URL.Query()
+	filter := histories.Filter{
+		JobId:  query.Get("id"),
+		Status: query.Get("status"),
+	}
+	var err error
+	if filter.From, err = parseQueryTime(query.Get("from")); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseQueryTime(query.Get("to")); err != nil {
+		return filter, err
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return filter, errors.New(fmt.Sprintf("invalid limit %q", limit))
+		}
+	}
+	return filter, nil
+}
+
+func parseQueryTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(jobs.ParseTimeLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("invalid time %q", s))
+	}
+	return t, nil
+}
+
+// route "/api/job/runs?id=xxx&status=failed&from=...&to=...&limit=20"，查询任务的执行记录api，按开始时间倒序
+func handleJobRuns(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	filter, err := parseRunFilter(r)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	if strings.EqualFold(filter.JobId, "") {
+		resp.Code = 1
+		resp.Message = "must supply a job id param"
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if scheduler.History == nil {
+		resp.Code = 1
+		resp.Message = "run history is not enabled"
+		return
+	}
+
+	resp.Message = "success"
+	resp.Data = scheduler.History.Query(filter)
+	return
+}
+
+// route "/api/runs?status=failed&from=...&to=...&limit=20"，查询所有任务的执行记录api，按开始时间倒序
+func handleRunsList(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	filter, err := parseRunFilter(r)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if scheduler.History == nil {
+		resp.Code = 1
+		resp.Message = "run history is not enabled"
+		return
+	}
+
+	resp.Message = "success"
+	resp.Data = scheduler.History.Query(filter)
+	return
+}
+
+// route "/api/run/?id=xxx"，查询一次执行的记录api
+func handleRunRead(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	id := r.URL.Query().Get("id")
+	if strings.EqualFold(id, "") {
+		resp.Code = 1
+		resp.Message = "must supply a run id param"
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if scheduler.History == nil {
+		resp.Code = 1
+		resp.Message = "run history is not enabled"
+		return
+	}
+
+	record := scheduler.History.Get(id)
+	if record == nil {
+		resp.Code = 1
+		resp.Message = "error: no such a run"
+		return
+	}
+	resp.Message = "success"
+	resp.Data = record
+	return
+}
+
+type runLogData struct {
+	Running   bool   `json:"running"`
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated"`
+}
+
+// route "/api/run/log?id=xxx&follow=true"，查询一次执行的输出api，id 为空时可传 job=任务 id 查询该任务正在执行的输出。
+// follow 为 true 时以 text/plain 持续输出正在执行的任务的输出，直到执行结束，不受服务器写超时(--wt)限制
+func handleRunLog(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+	if jobId := r.URL.Query().Get("job"); id == "" && jobId != "" {
+		if runIds := executors.LiveRunIds(jobId); len(runIds) > 0 {
+			id = runIds[0]
+		}
+	}
+
+	if runLog, ok := executors.LiveRunLog(id); ok && follow {
+		streamRunLog(w, r, runLog)
+		return
+	}
+
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	if strings.EqualFold(id, "") {
+		resp.Code = 1
+		resp.Message = "must supply a run id param"
+		return
+	}
+	if runLog, ok := executors.LiveRunLog(id); ok {
+		resp.Message = "success"
+		resp.Data = runLogData{Running: true, Output: runLog.String(), Truncated: runLog.Truncated()}
+		return
+	}
+
+	scheduler := schedulers.GetScheduler()
+	if scheduler.History == nil {
+		resp.Code = 1
+		resp.Message = "run history is not enabled"
+		return
+	}
+	record := scheduler.History.Get(id)
+	if record == nil {
+		resp.Code = 1
+		resp.Message = "error: no such a run"
+		return
+	}
+	resp.Message = "success"
+	resp.Data = runLogData{Output: record.Output, Truncated: record.OutputTruncated}
+	return
+}
+
+// runLogWriteTimeout follow 模式下单次写出的超时，客户端不再读取时结束输出
+const runLogWriteTimeout = 30 * time.Second
+
+// streamRunLog 持续写出执行的输出，直到执行结束或客户端断开。
+// 服务器的 WriteTimeout(--wt) 从请求开始计时，会中断长时间的输出，因此接管连接、取消该超时，
+// 以 Connection: close 响应并在输出结束时关闭连接
+func streamRunLog(w http.ResponseWriter, r *http.Request, runLog *executors.RunLog) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.Println("Error:", err)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetDeadline(time.Time{})
+
+	// 接管连接后 r.Context() 不再随客户端断开而取消，由读取连接得知客户端断开
+	closed := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, buf.Reader)
+		close(closed)
+	}()
+
+	_, _ = buf.WriteString("HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"X-Content-Type-Options: nosniff\r\n" +
+		"Cache-Control: no-cache\r\n" +
+		"Connection: close\r\n\r\n")
+	offset := 0
+	for {
+		data, changed, done := runLog.Read(offset)
+		_ = conn.SetWriteDeadline(time.Now().Add(runLogWriteTimeout))
+		_, _ = buf.Write(data)
+		offset += len(data)
+		if err := buf.Flush(); err != nil {
+			return
+		}
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-closed:
+			return
+		}
+	}
+}
+
+// remoteExecutor 返回调度器的远程执行器，调度器未使用远程执行器时返回错误
+func remoteExecutor() (*executors.RemoteExecutor, error) {
+	scheduler := schedulers.GetScheduler()
+	if !scheduler.IsRunning() {
+		return nil, errors.New("scheduler is not running")
+	}
+	remote, ok := scheduler.Executor.(*executors.RemoteExecutor)
+	if !ok {
+		return nil, errors.New("executor is not remote, start the scheduler with --executor-type remote")
+	}
+	return remote, nil
+}
+
+// route "/api/worker/register"，worker 注册api，body 为 worker 可执行的函数及任务类型
+func handleWorkerRegister(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var registration executors.WorkerRegistration
+	err := json.NewDecoder(r.Body).Decode(&registration)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	if err = jobs.ValidateLabels(registration.Labels); err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	remote, err := remoteExecutor()
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = remote.Register(registration)
+	return
+}
+
+// route "/api/worker/poll"，worker 领取执行api，没有可执行的任务时最多等待 wait 秒，data 为 null。
+// 等待时间不超过 executors.MaxPollWait，并在服务器写超时(--wt)之前返回
+func handleWorkerPoll(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var poll executors.PollRequest
+	err := json.NewDecoder(r.Body).Decode(&poll)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	remote, err := remoteExecutor()
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	wait := time.Duration(poll.Wait * float64(time.Second))
+	if limit := maxPollWait(r); wait > limit {
+		wait = limit
+	}
+	lease, err := remote.Poll(r.Context(), poll.WorkerId, wait)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = lease
+	return
+}
+
+// pollWaitMargin 长轮询在服务器写超时前结束的余量，留出写出响应的时间
+const pollWaitMargin = 5 * time.Second
+
+// maxPollWait 长轮询的最长等待时间，不超过 executors.MaxPollWait 及服务器的写超时(--wt)减去余量；
+// 写超时较短时取其一半
+func maxPollWait(r *http.Request) time.Duration {
+	wait := executors.MaxPollWait
+	server, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if !ok || server.WriteTimeout <= 0 {
+		return wait
+	}
+	limit := server.WriteTimeout - pollWaitMargin
+	if server.WriteTimeout <= 2*pollWaitMargin {
+		limit = server.WriteTimeout / 2
+	}
+	if limit < wait {
+		wait = limit
+	}
+	return wait
+}
+
+// route "/api/worker/heartbeat"，worker 续租并上报输出api，data 为应停止执行的租约
+func handleWorkerHeartbeat(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var heartbeat executors.Heartbeat
+	err := json.NewDecoder(r.Body).Decode(&heartbeat)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	remote, err := remoteExecutor()
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	reply, err := remote.Heartbeat(heartbeat)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = reply
+	return
+}
+
+// route "/api/worker/result"，worker 上报执行结果api
+func handleWorkerResult(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	var result executors.LeaseResult
+	err := json.NewDecoder(r.Body).Decode(&result)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+
+	remote, err := remoteExecutor()
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	err = remote.Complete(result)
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	return
+}
+
+// route "/api/workers"，查询已注册的 worker、正在执行的租约及等待分发的执行数api
+func handleWorkersList(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	remote, err := remoteExecutor()
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = remote.Status()
+	return
+}
+
+// route "/api/queues"，查询远程执行各队列等待分发及正在执行的任务数api
+func handleQueuesList(w http.ResponseWriter, r *http.Request) {
+	resp := &response{}
+	defer func() {
+		_ = jsonResponse(w, resp)
+	}()
+
+	remote, err := remoteExecutor()
+	if err != nil {
+		resp.Code = 1
+		resp.Message = err.Error()
+		return
+	}
+	resp.Message = "success"
+	resp.Data = remote.Queues()
+	return
+}
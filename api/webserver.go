@@ -93,7 +93,9 @@ func (server *WebServer) Start() {
 			_ = l.Close()
 		}()
 		log.Println("Server start at:", server.Addr)
-		_ = server.Serve(l)
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Println("Error:", err)
+		}
 	}
 }
 
@@ -153,5 +155,33 @@ func (mux *myServeMux) RegisterHandlers() {
 	mux.Handle("/api/job/add", chain(http.HandlerFunc(handleJobAdd), methodMiddleware("POST")))
 	mux.Handle("/api/job/delete", chain(http.HandlerFunc(handleJobDelete), methodMiddleware("POST")))
 	mux.Handle("/api/job/update", chain(http.HandlerFunc(handleJobUpdate), methodMiddleware("POST")))
+	mux.Handle("/api/job/events", chain(http.HandlerFunc(handleJobEvents), methodMiddleware("GET")))
+	mux.Handle("/api/job/runs", chain(http.HandlerFunc(handleJobRuns), methodMiddleware("GET")))
+	mux.Handle("/api/runs", chain(http.HandlerFunc(handleRunsList), methodMiddleware("GET")))
+	mux.Handle("/api/run/log", chain(http.HandlerFunc(handleRunLog), methodMiddleware("GET")))
+	mux.Handle("/api/run/", chain(http.HandlerFunc(handleRunRead), methodMiddleware("GET", "POST")))
 	mux.Handle("/api/job/", chain(http.HandlerFunc(handleJobRead), methodMiddleware("GET", "POST")))
+	mux.Handle("/api/calendars", chain(http.HandlerFunc(handleCalendarsList), methodMiddleware("GET")))
+	mux.Handle("/api/calendar/add", chain(http.HandlerFunc(handleCalendarAdd), methodMiddleware("POST")))
+	mux.Handle("/api/calendar/delete", chain(http.HandlerFunc(handleCalendarDelete), methodMiddleware("POST")))
+	mux.Handle("/api/calendar/update", chain(http.HandlerFunc(handleCalendarUpdate), methodMiddleware("POST")))
+	mux.Handle("/api/calendar/", chain(http.HandlerFunc(handleCalendarRead), methodMiddleware("GET", "POST")))
+	mux.Handle("/api/workflows", chain(http.HandlerFunc(handleWorkflowsList), methodMiddleware("GET")))
+	mux.Handle("/api/workflow/add", chain(http.HandlerFunc(handleWorkflowAdd), methodMiddleware("POST")))
+	mux.Handle("/api/workflow/delete", chain(http.HandlerFunc(handleWorkflowDelete), methodMiddleware("POST")))
+	mux.Handle("/api/workflow/run", chain(http.HandlerFunc(handleWorkflowRun), methodMiddleware("POST")))
+	mux.Handle("/api/workflow/cancel", chain(http.HandlerFunc(handleWorkflowCancel), methodMiddleware("POST")))
+	mux.Handle("/api/workflow/runs", chain(http.HandlerFunc(handleWorkflowRunsList), methodMiddleware("GET")))
+	mux.Handle("/api/workflow/run/", chain(http.HandlerFunc(handleWorkflowRunRead), methodMiddleware("GET", "POST")))
+	mux.Handle("/api/workflow/", chain(http.HandlerFunc(handleWorkflowRead), methodMiddleware("GET", "POST")))
+	mux.Handle("/api/deadletters", chain(http.HandlerFunc(handleDeadLettersList), methodMiddleware("GET")))
+	mux.Handle("/api/deadletter/replay", chain(http.HandlerFunc(handleDeadLetterReplay), methodMiddleware("POST")))
+	mux.Handle("/api/deadletter/delete", chain(http.HandlerFunc(handleDeadLetterDelete), methodMiddleware("POST")))
+	mux.Handle("/api/deadletter/", chain(http.HandlerFunc(handleDeadLetterRead), methodMiddleware("GET", "POST")))
+	mux.Handle("/api/workers", chain(http.HandlerFunc(handleWorkersList), methodMiddleware("GET")))
+	mux.Handle("/api/queues", chain(http.HandlerFunc(handleQueuesList), methodMiddleware("GET")))
+	mux.Handle("/api/worker/register", chain(http.HandlerFunc(handleWorkerRegister), methodMiddleware("POST")))
+	mux.Handle("/api/worker/poll", chain(http.HandlerFunc(handleWorkerPoll), methodMiddleware("POST")))
+	mux.Handle("/api/worker/heartbeat", chain(http.HandlerFunc(handleWorkerHeartbeat), methodMiddleware("POST")))
+	mux.Handle("/api/worker/result", chain(http.HandlerFunc(handleWorkerResult), methodMiddleware("POST")))
 }
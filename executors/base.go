@@ -1,51 +1,199 @@
 package executors
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"go-Job-Scheduler/jobs"
+	"log"
 	"reflect"
+	"time"
 )
 
-const DefaultMaxPoolSize = 10
+const (
+	DefaultMaxPoolSize = 10
+	// DefaultQueueSize 未设置队列长度时等待执行的最大任务数
+	DefaultQueueSize = 100
+)
+
+// 执行队列已满时的处理方式
+const (
+	// BackpressureBlock 阻塞等待队列有空位(默认)
+	BackpressureBlock = "block"
+	// BackpressureReject 丢弃本次执行并记录事件
+	BackpressureReject = "reject"
+	// BackpressureDefer 交还调用方，由调度器放回 store 稍后重新提交；
+	// 调度器总是以 TryAdd 提交，block 时同样放回 store，避免阻塞期间到期任务不在 store 中
+	BackpressureDefer = "defer"
+)
+
+var (
+	ErrRejected = errors.New("executor queue is full, run rejected")
+	ErrDeferred = errors.New("executor queue is full, run deferred")
+	ErrShutdown = errors.New("executor is shut down")
+)
 
 var (
-	registeredFuncMap = make(map[string]interface{})
-	executors         = make(map[string]Executor)
+	executors = make(map[string]Executor)
+	// kindRunners 各种任务类型的执行方式，见 jobs.KindFunc 等
+	kindRunners = make(map[string]func(ctx context.Context, job jobs.Job) ([]reflect.Value, error))
 )
 
 type Executor interface {
-	Add(job jobs.Job)
+	// Add 将任务放入执行队列，队列已满时按 Backpressure 阻塞或返回错误
+	Add(job jobs.Job) error
+	// TryAdd 与 Add 相同但不阻塞，队列已满且 Backpressure 为 block 或 defer 时返回 ErrDeferred
+	TryAdd(job jobs.Job) error
 	setOption(option ExecutorOption)
-	Execute()
+	// Cancel 取消任务正在运行的所有实例
+	Cancel(jobId string)
+	// Shutdown 取消所有正在运行的任务并等待其返回
+	Shutdown()
 }
 
 type ExecutorOption struct {
-	PoolSize int
+	PoolSize     int
+	QueueSize    int
+	Backpressure string
+	RunLogSize   int
+	// ShellAllowList 允许 shell 类型任务执行的命令，ShellEnvAllowList 可设置的环境变量名，ShellDirAllowList 可使用的工作目录
+	ShellAllowList    []string
+	ShellEnvAllowList []string
+	ShellDirAllowList []string
+	// LeaseTimeout 远程 worker 未续租时重新分发执行的时间
+	LeaseTimeout time.Duration
+	// QueueLimits 远程执行时每个队列同时执行的最大任务数，未设置的队列不限
+	QueueLimits map[string]int
+}
+
+var (
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// runFunc 执行 func 类型任务，以任务的 Args 及 Kwargs 调用注册的函数
+func runFunc(ctx context.Context, job jobs.Job) ([]reflect.Value, error) {
+	return call(ctx, job.FuncName, job.Args, job.Kwargs)
+}
+
+// execute 按任务类型执行任务
+func execute(ctx context.Context, job jobs.Job) ([]reflect.Value, error) {
+	runner, ok := kindRunners[job.KindOf()]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unsupported job kind %q", job.Kind))
+	}
+	return runner(ctx, job)
+}
+
+// invoke 在 ctx 的超时时间内执行任务。超时或被取消时立即返回结果，不等待函数返回，
+// 函数需通过第一个参数 context.Context 感知取消并自行退出；返回的 done 在函数返回后关闭，
+// 调用方需等待 done 后再释放 worker 及实例数，避免忽略取消的函数使同时运行的数量超过限制
+func invoke(ctx context.Context, job jobs.Job) ([]reflect.Value, string, <-chan struct{}, error) {
+	type outcome struct {
+		values []reflect.Value
+		err    error
+	}
+	ch := make(chan outcome, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		values, err := execute(ctx, job)
+		ch <- outcome{values, err}
+	}()
+
+	select {
+	case o := <-ch:
+		if o.err != nil {
+			return o.values, jobs.RunFailed, done, o.err
+		}
+		return o.values, jobs.RunSucceeded, done, nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			recordEvent(job.Id, EventTimedOut, fmt.Sprintf("timed out after %s", job.Timeout))
+			return nil, jobs.RunTimedOut, done, errors.New(fmt.Sprintf("timed out after %s", job.Timeout))
+		}
+		recordEvent(job.Id, EventCancelled, "cancelled")
+		return nil, jobs.RunCancelled, done, errors.New("cancelled")
+	}
+}
+
+// waitReturned 等待超时或被取消后仍在运行的函数返回，stop 关闭(执行器关闭)时不再等待
+func waitReturned(job jobs.Job, done <-chan struct{}, stop <-chan struct{}) {
+	select {
+	case <-done:
+		return
+	default:
+	}
+	log.Println("Job", job.Id, "still running after it was stopped, waiting for it to return")
+	select {
+	case <-done:
+		log.Println("Job", job.Id, "returned")
+	case <-stop:
+	}
 }
 
-func call(funcName string, params ...interface{}) (result []reflect.Value, err error) {
-	f := reflect.ValueOf(registeredFuncMap[funcName])
-	if len(params) != f.Type().NumIn() {
-		err = errors.New("number of params is invalid")
+// chain 将执行结果传给 onSuccess 后续任务并提交给执行器
+func chain(executor Executor, job jobs.Job, values []reflect.Value) {
+	results := make([]interface{}, len(values))
+	for i, v := range values {
+		results[i] = valueInterface(v)
+	}
+	next, err := job.FollowUp(results)
+	if err != nil {
+		log.Println("Error:", job.Id, err)
 		return
 	}
-	in := make([]reflect.Value, len(params))
-	for i, param := range params {
-		in[i] = reflect.ValueOf(param)
+	// 队列已满时 Add 可能阻塞，不占用当前 worker 等待
+	go func() {
+		if err := executor.Add(next); err != nil {
+			log.Println("Error:", next.Id, err)
+		}
+	}()
+}
+
+// ValidateJob 提交任务时检查执行器能否执行该任务及其后续任务，func 类型任务检查函数已注册且参数类型正确
+func ValidateJob(job jobs.Job) error {
+	for next := &job; next != nil; next = next.OnSuccess {
+		if _, ok := kindRunners[next.KindOf()]; !ok {
+			return errors.New(fmt.Sprintf("unsupported job kind %q", next.Kind))
+		}
+		if next.KindOf() == jobs.KindFunc {
+			// 只有后续任务的 ResultArgs 生效
+			var resultArgs []jobs.ResultArg
+			if next != &job {
+				resultArgs = next.ResultArgs
+			}
+			if err := validateFuncArgs(next, resultArgs); err != nil {
+				return err
+			}
+		}
+		if next.KindOf() == jobs.KindShell && next.Shell != nil {
+			if err := checkShell(next.Shell); err != nil {
+				return err
+			}
+		}
 	}
-	result = f.Call(in)
-	return
+	return nil
 }
 
 func registerExecutors() {
 	// 基础的执行器，将来可添加其他类型执行器
 	executors["base"] = newBaseExecutor()
+	// 远程执行器，任务由 worker 进程执行
+	executors["remote"] = newRemoteExecutor()
 }
 
 func init() {
 	// 注册各种任务的执行函数
-	registeredFuncMap["add"] = DoAdd
-	registeredFuncMap["print"] = DoPrint
+	MustRegister("add", DoAdd)
+	MustRegister("print", DoPrint)
+	MustRegister("sleep", DoSleep)
+	// 注册各种任务类型的执行方式
+	kindRunners[jobs.KindFunc] = runFunc
+	kindRunners[jobs.KindShell] = runShell
+	kindRunners[jobs.KindHTTP] = runHTTP
+	kindRunners[jobs.KindGRPC] = runGRPC
 	// 注册各种执行器
 	registerExecutors()
 }
@@ -59,6 +207,44 @@ func MapToExecutorOption(m map[string]interface{}) ExecutorOption {
 	} else {
 		option.PoolSize = DefaultMaxPoolSize
 	}
+
+	if v, ok := m["queueSize"].(int); ok {
+		option.QueueSize = v
+	} else {
+		option.QueueSize = DefaultQueueSize
+	}
+
+	if v, ok := m["backpressure"].(string); ok {
+		option.Backpressure = v
+	} else {
+		option.Backpressure = BackpressureBlock
+	}
+
+	if v, ok := m["runLogSize"].(int); ok {
+		option.RunLogSize = v
+	} else {
+		option.RunLogSize = DefaultRunLogSize
+	}
+
+	if v, ok := m["shellAllowList"].([]string); ok {
+		option.ShellAllowList = v
+	}
+	if v, ok := m["shellEnvAllowList"].([]string); ok {
+		option.ShellEnvAllowList = v
+	}
+	if v, ok := m["shellDirAllowList"].([]string); ok {
+		option.ShellDirAllowList = v
+	}
+
+	if v, ok := m["leaseTimeout"].(time.Duration); ok {
+		option.LeaseTimeout = v
+	} else {
+		option.LeaseTimeout = DefaultLeaseTimeout
+	}
+
+	if v, ok := m["queueLimits"].(map[string]int); ok {
+		option.QueueLimits = v
+	}
 	return option
 }
 
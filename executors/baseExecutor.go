@@ -1,52 +1,218 @@
 package executors
 
 import (
+	"context"
+	"github.com/google/uuid"
 	"go-Job-Scheduler/jobs"
 	"log"
+	"reflect"
 	"sync"
+	"time"
 )
 
 type BaseExecutor struct {
-	PoolSize int
-	Pool     []jobs.Job
+	// PoolSize worker 数量，QueueSize 等待执行的队列长度，Backpressure 队列已满时的处理方式
+	PoolSize     int
+	QueueSize    int
+	Backpressure string
+	// RunLogSize 每次执行保存的最大输出字节数
+	RunLogSize int
+	queue      chan jobs.Job
+	startOnce  sync.Once
+	mu         sync.Mutex
+	*instances
+	// ctx 所有执行的根 context，Shutdown 时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	// cancels 每个任务正在运行的实例的取消函数，按原任务 id 及执行序号索引
+	cancels   map[string]map[uint64]context.CancelFunc
+	nextRunId uint64
+	workers   sync.WaitGroup
 }
 
+// setOption 设置 worker 数量及队列，并启动 worker
 func (this *BaseExecutor) setOption(option ExecutorOption) {
-	this.PoolSize = option.PoolSize
+	this.startOnce.Do(func() {
+		this.PoolSize = option.PoolSize
+		if this.PoolSize <= 0 {
+			this.PoolSize = DefaultMaxPoolSize
+		}
+		this.QueueSize = option.QueueSize
+		if this.QueueSize <= 0 {
+			this.QueueSize = DefaultQueueSize
+		}
+		this.Backpressure = option.Backpressure
+		switch this.Backpressure {
+		case BackpressureBlock, BackpressureReject, BackpressureDefer:
+		default:
+			log.Println("Error: invalid executor backpressure", this.Backpressure, ", using", BackpressureBlock)
+			this.Backpressure = BackpressureBlock
+		}
+		this.RunLogSize = option.RunLogSize
+		if this.RunLogSize <= 0 {
+			this.RunLogSize = DefaultRunLogSize
+		}
+		SetShellAllowList(option.ShellAllowList, option.ShellEnvAllowList, option.ShellDirAllowList)
+		this.queue = make(chan jobs.Job, this.QueueSize)
+		for i := 0; i < this.PoolSize; i++ {
+			this.workers.Add(1)
+			go this.work()
+		}
+	})
+}
+
+// Add 将任务放入执行队列。队列已满时按 Backpressure 阻塞等待，
+// 或返回 ErrRejected(丢弃本次执行) / ErrDeferred(由调用方稍后重新提交)
+func (this *BaseExecutor) Add(job jobs.Job) error {
+	err := this.TryAdd(job)
+	if err != ErrDeferred || this.Backpressure != BackpressureBlock {
+		return err
+	}
+	select {
+	case this.queue <- job:
+		return nil
+	case <-this.ctx.Done():
+		return ErrShutdown
+	}
+}
+
+// TryAdd 将任务放入执行队列，队列已满时不阻塞，Backpressure 为 block 时也返回 ErrDeferred
+func (this *BaseExecutor) TryAdd(job jobs.Job) error {
+	if this.ctx.Err() != nil {
+		return ErrShutdown
+	}
+	select {
+	case this.queue <- job:
+		return nil
+	default:
+	}
+	switch this.Backpressure {
+	case BackpressureReject:
+		log.Println("Rejected job", job.Id, ": executor queue is full")
+		recordEvent(job.Id, EventRejected, "rejected: executor queue is full")
+		return ErrRejected
+	case BackpressureDefer:
+		recordEvent(job.Id, EventDeferred, "deferred: executor queue is full")
+	}
+	return ErrDeferred
+}
+
+// work worker 从队列中取出任务执行，直到 Shutdown
+func (this *BaseExecutor) work() {
+	defer this.workers.Done()
+	for {
+		select {
+		case <-this.ctx.Done():
+			return
+		case job := <-this.queue:
+			if this.acquire(job) {
+				this.run(job)
+			}
+		}
+	}
 }
 
-func (this *BaseExecutor) Add(job jobs.Job) {
-	this.Pool = append(this.Pool, job)
+// run 执行任务，结束后继续执行该任务排队中的执行
+func (this *BaseExecutor) run(job jobs.Job) {
+	for {
+		log.Println("Executing job", job.Id)
+		runId := uuid.New().String()
+		runLog := newRunLog(job.OriginalId(), this.RunLogSize)
+		registerRunLog(runId, runLog)
+		start := time.Now()
+		values, status, returned, err := this.invoke(job, runLog)
+		runLog.close()
+		if err != nil {
+			// 失败的执行由 scheduler 的结果监听按重试策略重试
+			log.Println("Error:", job.Id, err)
+		}
+		log.Println("Executing job", job.Id, ". Done", status)
+		notify(Result{RunId: runId, Job: job, StartTime: start, EndTime: time.Now(), Values: values, Status: status, Err: err, Output: runLog})
+		// 执行记录保存后不再作为正在执行的输出
+		unregisterRunLog(runId)
+		if status == jobs.RunSucceeded && job.OnSuccess != nil {
+			chain(this, job, values)
+		}
+		// 函数返回后才释放 worker 及实例数
+		waitReturned(job, returned, this.ctx.Done())
+
+		next, ok := this.release(job)
+		if !ok {
+			return
+		}
+		job = next
+	}
 }
 
-func (this *BaseExecutor) Execute() {
-	var wg sync.WaitGroup
-	var runningJobs int
-	// TODO: 添加任务执行耗时、状态监测等
-	for _, v := range this.Pool {
-		if runningJobs < this.PoolSize {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				log.Println("Executing job", v.Id)
-				_, err := call(v.FuncName, v.Args...)
-				if err != nil {
-					log.Println("Error:", v.Id, err)
-					// TODO: add failed job retries
-				}
-				log.Println("Executing job", v.Id, ". Done")
-			}()
-			runningJobs++
+// invoke 在任务的超时时间内执行任务，函数的输出写入 runLog，返回的 chan 在函数返回后关闭
+func (this *BaseExecutor) invoke(job jobs.Job, runLog *RunLog) ([]reflect.Value, string, <-chan struct{}, error) {
+	ctx, done := this.runContext(job)
+	defer done()
+	// 函数通过 Logger(ctx) / RunOutput(ctx) 写入本次执行的输出
+	return invoke(context.WithValue(ctx, runLogKey{}, runLog), job)
+}
+
+// runContext 创建一次执行的 context，返回执行结束后调用的清理函数
+func (this *BaseExecutor) runContext(job jobs.Job) (context.Context, func()) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if job.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(this.ctx, job.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(this.ctx)
+	}
+	// 重试任务随原任务一起取消
+	key := job.OriginalId()
+	this.nextRunId++
+	runId := this.nextRunId
+	if this.cancels[key] == nil {
+		this.cancels[key] = make(map[uint64]context.CancelFunc)
+	}
+	this.cancels[key][runId] = cancel
+
+	return ctx, func() {
+		this.mu.Lock()
+		delete(this.cancels[key], runId)
+		if len(this.cancels[key]) == 0 {
+			delete(this.cancels, key)
 		}
+		this.mu.Unlock()
+		cancel()
+	}
+}
+
+// Cancel 取消任务正在运行的所有实例，并丢弃排队中的执行
+func (this *BaseExecutor) Cancel(jobId string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	for _, cancel := range this.cancels[jobId] {
+		cancel()
+	}
+	this.dropPending(jobId)
+}
+
+// Shutdown 取消所有正在运行的任务，并等待所有 worker 退出，队列中尚未执行的任务不再执行
+func (this *BaseExecutor) Shutdown() {
+	this.mu.Lock()
+	this.cancel()
+	this.dropPending("")
+	this.mu.Unlock()
+	this.workers.Wait()
+	if n := len(this.queue); n > 0 {
+		log.Println("Executor shut down with", n, "queued runs not executed")
 	}
-	wg.Wait()
-	// 执行完清空 Pool
-	this.Pool = this.Pool[0:0]
 }
 
 func newBaseExecutor() Executor {
 	executor := &BaseExecutor{
-		PoolSize: 10,
+		PoolSize:  10,
+		instances: newInstances(),
+		cancels:   make(map[string]map[uint64]context.CancelFunc),
 	}
+	executor.ctx, executor.cancel = context.WithCancel(context.Background())
 	return executor
 }
@@ -0,0 +1,391 @@
+package executors
+
+import (
+	"context"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gate 控制 test.wait 函数的执行：started 在函数开始执行时关闭，关闭 release 后函数返回
+type gate struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+var (
+	gates sync.Map
+	// running、maxRunning 正在执行及同时执行最多的 test.count 数
+	running    int32
+	maxRunning int32
+
+	watchersMu sync.Mutex
+	watchers   = make(map[string]chan Result)
+)
+
+func init() {
+	MustRegister("test.wait", func(ctx context.Context, key string) error {
+		v, _ := gates.Load(key)
+		g := v.(*gate)
+		close(g.started)
+		select {
+		case <-g.release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	// test.stubborn 忽略 ctx 的取消，直到 gate 的 release 关闭
+	MustRegister("test.stubborn", func(ctx context.Context, key string) {
+		v, _ := gates.Load(key)
+		g := v.(*gate)
+		close(g.started)
+		<-g.release
+	})
+	MustRegister("test.count", func(ctx context.Context) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	})
+	MustRegister("test.block", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	// 按任务 id 前缀将执行结果转发给测试
+	AddResultListener(func(result Result) {
+		watchersMu.Lock()
+		defer watchersMu.Unlock()
+		for prefix, ch := range watchers {
+			if strings.HasPrefix(result.Job.Id, prefix) {
+				ch <- result
+			}
+		}
+	})
+}
+
+// watch 返回 id 以 prefix 开头的任务的执行结果
+func watch(t *testing.T, prefix string, size int) chan Result {
+	ch := make(chan Result, size)
+	watchersMu.Lock()
+	watchers[prefix] = ch
+	watchersMu.Unlock()
+	t.Cleanup(func() {
+		watchersMu.Lock()
+		delete(watchers, prefix)
+		watchersMu.Unlock()
+	})
+	return ch
+}
+
+func newGate(key string) *gate {
+	g := &gate{started: make(chan struct{}), release: make(chan struct{})}
+	gates.Store(key, g)
+	return g
+}
+
+func newTestBaseExecutor(t *testing.T, option ExecutorOption) *BaseExecutor {
+	executor := newBaseExecutor().(*BaseExecutor)
+	executor.setOption(option)
+	t.Cleanup(executor.Shutdown)
+	return executor
+}
+
+// results 等待 n 个执行结果
+func results(t *testing.T, ch chan Result, n int) []Result {
+	var received []Result
+	timeout := time.After(10 * time.Second)
+	for len(received) < n {
+		select {
+		case result := <-ch:
+			received = append(received, result)
+		case <-timeout:
+			t.Fatalf("received %d of %d results", len(received), n)
+		}
+	}
+	return received
+}
+
+func waitStarted(t *testing.T, g *gate) {
+	select {
+	case <-g.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not start")
+	}
+}
+
+func TestBaseExecutorRunsAllJobs(t *testing.T) {
+	const poolSize, producers, perProducer = 4, 8, 50
+	ch := watch(t, "count/", producers*perProducer)
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: poolSize, QueueSize: 8, Backpressure: BackpressureBlock})
+	atomic.StoreInt32(&maxRunning, 0)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				if err := executor.Add(jobs.Job{Id: fmt.Sprintf("count/%d/%d", p, i), FuncName: "test.count"}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, result := range results(t, ch, producers*perProducer) {
+		if result.Status != jobs.RunSucceeded {
+			t.Fatalf("%s: %s %v", result.Job.Id, result.Status, result.Err)
+		}
+		if seen[result.Job.Id] {
+			t.Fatalf("%s executed twice", result.Job.Id)
+		}
+		seen[result.Job.Id] = true
+	}
+	if max := atomic.LoadInt32(&maxRunning); max > poolSize {
+		t.Fatalf("%d jobs ran at the same time, pool size is %d", max, poolSize)
+	}
+}
+
+// fillQueue 使唯一的 worker 执行 <prefix>running 并占满长度为 1 的队列，返回两个任务的 gate
+func fillQueue(t *testing.T, executor *BaseExecutor, prefix string) (*gate, *gate) {
+	runningGate, queuedGate := newGate(prefix+"running"), newGate(prefix+"queued")
+	if err := executor.Add(jobs.Job{Id: prefix + "running", FuncName: "test.wait", Args: []interface{}{prefix + "running"}}); err != nil {
+		t.Fatal(err)
+	}
+	waitStarted(t, runningGate)
+	if err := executor.Add(jobs.Job{Id: prefix + "queued", FuncName: "test.wait", Args: []interface{}{prefix + "queued"}}); err != nil {
+		t.Fatal(err)
+	}
+	return runningGate, queuedGate
+}
+
+func TestBaseExecutorBackpressure(t *testing.T) {
+	for _, backpressure := range []string{BackpressureReject, BackpressureDefer} {
+		prefix := backpressure + "/"
+		ch := watch(t, prefix, 3)
+		executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 1, QueueSize: 1, Backpressure: backpressure})
+		runningGate, queuedGate := fillQueue(t, executor, prefix)
+
+		want := map[string]error{BackpressureReject: ErrRejected, BackpressureDefer: ErrDeferred}[backpressure]
+		if err := executor.Add(jobs.Job{Id: prefix + "overflow", FuncName: "test.count"}); err != want {
+			t.Fatalf("%s: Add returned %v, want %v", backpressure, err, want)
+		}
+		close(runningGate.release)
+		close(queuedGate.release)
+		for _, result := range results(t, ch, 2) {
+			if result.Status != jobs.RunSucceeded {
+				t.Fatalf("%s: %s %s", backpressure, result.Job.Id, result.Status)
+			}
+		}
+	}
+}
+
+func TestBaseExecutorBackpressureBlock(t *testing.T) {
+	ch := watch(t, "block/", 3)
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 1, QueueSize: 1, Backpressure: BackpressureBlock})
+	runningGate, queuedGate := fillQueue(t, executor, "block/")
+
+	// 调度器使用的 TryAdd 不阻塞，由调度器放回 store 稍后重新提交
+	if err := executor.TryAdd(jobs.Job{Id: "block/try", FuncName: "test.count"}); err != ErrDeferred {
+		t.Fatalf("TryAdd returned %v, want %v", err, ErrDeferred)
+	}
+	if events := Events("block/try"); len(events) != 0 {
+		t.Fatalf("recorded %v", events)
+	}
+
+	added := make(chan error, 1)
+	go func() {
+		added <- executor.Add(jobs.Job{Id: "block/overflow", FuncName: "test.count"})
+	}()
+	select {
+	case err := <-added:
+		t.Fatalf("Add returned %v while the queue was full", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(runningGate.release)
+	if err := <-added; err != nil {
+		t.Fatal(err)
+	}
+	close(queuedGate.release)
+	// 阻塞的执行没有丢失
+	for _, result := range results(t, ch, 3) {
+		if result.Status != jobs.RunSucceeded {
+			t.Fatalf("%s: %s", result.Job.Id, result.Status)
+		}
+	}
+}
+
+func TestBaseExecutorConcurrentCancel(t *testing.T) {
+	const n = 50
+	ch := watch(t, "cancel/", n)
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 8, QueueSize: n, Backpressure: BackpressureBlock})
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		id := fmt.Sprintf("cancel/%d", i)
+		go func() {
+			defer wg.Done()
+			if err := executor.Add(jobs.Job{Id: id, FuncName: "test.block"}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			executor.Cancel(id)
+		}()
+	}
+	wg.Wait()
+	// Cancel 可能早于执行开始，再次取消所有任务
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+				for i := 0; i < n; i++ {
+					executor.Cancel(fmt.Sprintf("cancel/%d", i))
+				}
+			}
+		}
+	}()
+	defer close(done)
+	for _, result := range results(t, ch, n) {
+		if result.Status != jobs.RunCancelled {
+			t.Fatalf("%s: %s", result.Job.Id, result.Status)
+		}
+	}
+}
+
+func TestBaseExecutorShutdown(t *testing.T) {
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 4, QueueSize: 4, Backpressure: BackpressureBlock})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := executor.Add(jobs.Job{Id: fmt.Sprintf("shutdown/%d", i), FuncName: "test.block"})
+			if err != nil && err != ErrShutdown {
+				t.Error(err)
+			}
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		executor.Shutdown()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+	// 阻塞在队列上的 Add 随 Shutdown 返回
+	wg.Wait()
+	if err := executor.Add(jobs.Job{Id: "shutdown/late", FuncName: "test.count"}); err != ErrShutdown {
+		t.Fatalf("Add after Shutdown returned %v", err)
+	}
+}
+
+func TestBaseExecutorHoldsSlotUntilReturn(t *testing.T) {
+	ch := watch(t, "stubborn/", 2)
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 1, QueueSize: 1, Backpressure: BackpressureBlock})
+	stubborn, next := newGate("stubborn/timeout"), newGate("stubborn/next")
+	job := jobs.Job{Id: "stubborn/timeout", FuncName: "test.stubborn", Args: []interface{}{"stubborn/timeout"}, Timeout: 20 * time.Millisecond}
+	if err := executor.Add(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := executor.Add(jobs.Job{Id: "stubborn/next", FuncName: "test.wait", Args: []interface{}{"stubborn/next"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超时的结果立即上报
+	if result := results(t, ch, 1)[0]; result.Job.Id != job.Id || result.Status != jobs.RunTimedOut {
+		t.Fatalf("%s: %s", result.Job.Id, result.Status)
+	}
+	// 函数返回前 worker 不执行下一个任务
+	select {
+	case <-next.started:
+		t.Fatal("next job started while the timed out function was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(stubborn.release)
+	waitStarted(t, next)
+	close(next.release)
+	if result := results(t, ch, 1)[0]; result.Status != jobs.RunSucceeded {
+		t.Fatalf("%s: %s", result.Job.Id, result.Status)
+	}
+}
+
+func TestBaseExecutorRetryCountsAgainstOriginal(t *testing.T) {
+	ch := watch(t, "instances/", 2)
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 2, QueueSize: 2, Backpressure: BackpressureBlock})
+	original, retry := newGate("instances/job"), newGate("instances/job/retry/1")
+	if err := executor.Add(jobs.Job{Id: "instances/job", FuncName: "test.wait", Args: []interface{}{"instances/job"},
+		MaxInstances: 1, InstancePolicy: jobs.InstanceQueue}); err != nil {
+		t.Fatal(err)
+	}
+	waitStarted(t, original)
+	// 重试任务 id 不同，但与原任务共用实例数
+	if err := executor.Add(jobs.Job{Id: "instances/job/retry/1", RetryOf: "instances/job", FuncName: "test.wait", Args: []interface{}{"instances/job/retry/1"},
+		MaxInstances: 1, InstancePolicy: jobs.InstanceQueue}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-retry.started:
+		t.Fatal("retry started while the original job was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(original.release)
+	waitStarted(t, retry)
+	close(retry.release)
+	for _, result := range results(t, ch, 2) {
+		if result.Status != jobs.RunSucceeded {
+			t.Fatalf("%s: %s", result.Job.Id, result.Status)
+		}
+	}
+}
+
+func TestBaseExecutorCancelDropsQueuedRetry(t *testing.T) {
+	ch := watch(t, "dropretry/", 2)
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 2, QueueSize: 2, Backpressure: BackpressureBlock})
+	original, retry := newGate("dropretry/job"), newGate("dropretry/job/retry/1")
+	if err := executor.Add(jobs.Job{Id: "dropretry/job", FuncName: "test.wait", Args: []interface{}{"dropretry/job"},
+		InstancePolicy: jobs.InstanceQueue}); err != nil {
+		t.Fatal(err)
+	}
+	waitStarted(t, original)
+	if err := executor.Add(jobs.Job{Id: "dropretry/job/retry/1", RetryOf: "dropretry/job", FuncName: "test.wait", Args: []interface{}{"dropretry/job/retry/1"},
+		InstancePolicy: jobs.InstanceQueue}); err != nil {
+		t.Fatal(err)
+	}
+	// 等待重试进入实例排队
+	time.Sleep(20 * time.Millisecond)
+	executor.Cancel("dropretry/job")
+	if result := results(t, ch, 1)[0]; result.Job.Id != "dropretry/job" || result.Status != jobs.RunCancelled {
+		t.Fatalf("%s: %s", result.Job.Id, result.Status)
+	}
+	select {
+	case <-retry.started:
+		t.Fatal("queued retry ran after Cancel")
+	case result := <-ch:
+		t.Fatalf("%s: %s after Cancel", result.Job.Id, result.Status)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,59 @@
+package executors
+
+import (
+	"sync"
+	"time"
+)
+
+// 执行事件类型
+const (
+	EventSkipped   = "skipped"
+	EventQueued    = "queued"
+	EventCoalesced = "coalesced"
+	EventTimedOut  = "timed_out"
+	EventCancelled = "cancelled"
+	EventRejected  = "rejected"
+	EventDeferred  = "deferred"
+	// EventLeaseExpired 远程 worker 未按时续租，执行被重新分发
+	EventLeaseExpired = "lease_expired"
+)
+
+// maxEvents 内存中保留的最近事件数
+const maxEvents = 1000
+
+// Event 任务执行过程中的事件，如达到最大实例数被跳过
+type Event struct {
+	JobId   string    `json:"jobId"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+}
+
+var (
+	eventsMu sync.RWMutex
+	events   []Event
+)
+
+func recordEvent(jobId, eventType, message string) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
+	events = append(events, Event{JobId: jobId, Time: time.Now(), Type: eventType, Message: message})
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+}
+
+// Events 返回任务最近的执行事件，jobId 为空时返回所有任务的事件
+func Events(jobId string) []Event {
+	eventsMu.RLock()
+	defer eventsMu.RUnlock()
+
+	var result []Event
+	for _, e := range events {
+		if jobId == "" || e.JobId == jobId {
+			result = append(result, e)
+		}
+	}
+	return result
+}
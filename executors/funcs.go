@@ -0,0 +1,285 @@
+package executors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	funcsMu sync.RWMutex
+	// registeredFuncMap 通过 Register 注册的任务函数
+	registeredFuncMap = make(map[string]*registeredFunc)
+
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// registeredFunc 注册的任务函数及其参数类型
+type registeredFunc struct {
+	name string
+	fn   reflect.Value
+	// withContext 第一个参数为 context.Context，调用时传入本次执行的 ctx
+	withContext bool
+	// params 除 context.Context 外的参数类型，可变参数函数的最后一个为切片类型
+	params   []reflect.Type
+	variadic bool
+	// kwargs 最后一个参数为结构体，可由任务的 Kwargs 按字段名填充
+	kwargs bool
+}
+
+// Register 注册任务函数，任务通过 funcName 调用。注册时校验函数签名：
+// 第一个参数可以为 context.Context，其他参数须能由 JSON 转换(数字、字符串、结构体、切片、map、time.Time 等，
+// time.Duration 参数可传秒数或 "1m30s" 格式的字符串)，支持可变参数；
+// 最后一个参数为结构体时可由任务的 kwargs 按字段名填充(此时 args 不传该参数)；返回值中的 error 只能为最后一个
+func Register(name string, fn interface{}) error {
+	if name == "" {
+		return errors.New("function name must not be empty")
+	}
+	f, err := newRegisteredFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	if _, ok := registeredFuncMap[name]; ok {
+		return errors.New(fmt.Sprintf("function %s is already registered", name))
+	}
+	registeredFuncMap[name] = f
+	return nil
+}
+
+// MustRegister 同 Register，注册失败时 panic，用于 init 中注册
+func MustRegister(name string, fn interface{}) {
+	if err := Register(name, fn); err != nil {
+		panic(err)
+	}
+}
+
+// lookupFunc 查找注册的函数
+func lookupFunc(name string) (*registeredFunc, error) {
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+	f, ok := registeredFuncMap[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no such function %s", name))
+	}
+	return f, nil
+}
+
+// registeredFuncNames 返回注册的函数名
+func registeredFuncNames() []string {
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+	names := make([]string, 0, len(registeredFuncMap))
+	for name := range registeredFuncMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newRegisteredFunc(name string, fn interface{}) (*registeredFunc, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, errors.New(fmt.Sprintf("function %s: %T is not a function", name, fn))
+	}
+	t := v.Type()
+	f := &registeredFunc{name: name, fn: v, variadic: t.IsVariadic()}
+	for i := 0; i < t.NumIn(); i++ {
+		param := t.In(i)
+		if i == 0 && param == contextType {
+			f.withContext = true
+			continue
+		}
+		if f.variadic && i == t.NumIn()-1 {
+			param = param.Elem()
+		}
+		if err := checkParamType(param); err != nil {
+			return nil, errors.New(fmt.Sprintf("function %s: param %d: %s", name, i, err.Error()))
+		}
+		f.params = append(f.params, t.In(i))
+	}
+	if n := len(f.params); !f.variadic && n > 0 && f.params[n-1].Kind() == reflect.Struct && f.params[n-1] != timeType {
+		f.kwargs = true
+	}
+	for i := 0; i < t.NumOut()-1; i++ {
+		if t.Out(i) == errorType {
+			return nil, errors.New(fmt.Sprintf("function %s: error must be the last return value", name))
+		}
+	}
+	return f, nil
+}
+
+// checkParamType 检查参数类型能否由 JSON 转换
+func checkParamType(t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return errors.New(fmt.Sprintf("unsupported type %s", t))
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			return errors.New(fmt.Sprintf("unsupported interface type %s, use interface{}", t))
+		}
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return checkParamType(t.Elem())
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return errors.New(fmt.Sprintf("unsupported map key type %s", t.Key()))
+		}
+		return checkParamType(t.Elem())
+	}
+	return nil
+}
+
+// bind 将任务参数及 kwargs 转换为函数声明的参数类型，skip 中的参数(由上一个任务的返回值填充)不转换，以零值代替
+func (f *registeredFunc) bind(args []interface{}, kwargs map[string]interface{}, skip map[int]bool) ([]reflect.Value, error) {
+	fixed := len(f.params)
+	if f.variadic {
+		fixed--
+	}
+	// 传入 kwargs 且未传入最后一个结构体参数时由 kwargs 填充；未传 kwargs 时须在 args 中传入该参数，
+	// 避免漏传的参数被静默地以零值调用
+	fromKwargs := f.kwargs && len(kwargs) > 0 && len(args) == fixed-1
+	switch {
+	case len(kwargs) > 0 && !f.kwargs:
+		return nil, errors.New(fmt.Sprintf("function %s does not take kwargs", f.name))
+	case len(kwargs) > 0 && !fromKwargs:
+		return nil, errors.New(fmt.Sprintf("function %s takes kwargs in place of its last arg, got %d args", f.name, len(args)))
+	case f.variadic && len(args) < fixed:
+		return nil, errors.New(fmt.Sprintf("function %s expects at least %d args, got %d", f.name, fixed, len(args)))
+	case !f.variadic && !fromKwargs && len(args) != fixed:
+		return nil, errors.New(fmt.Sprintf("function %s expects %d args, got %d", f.name, fixed, len(args)))
+	}
+
+	in := make([]reflect.Value, 0, len(args)+1)
+	for i, arg := range args {
+		var t reflect.Type
+		if i < fixed {
+			t = f.params[i]
+		} else {
+			t = f.params[fixed].Elem()
+		}
+		if skip[i] {
+			in = append(in, reflect.Zero(t))
+			continue
+		}
+		v, err := convertArg(arg, t)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("function %s: arg %d: %s", f.name, i, err.Error()))
+		}
+		in = append(in, v)
+	}
+	if fromKwargs {
+		v, err := convertArg(kwargs, f.params[fixed-1])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("function %s: kwargs: %s", f.name, err.Error()))
+		}
+		in = append(in, v)
+	}
+	return in, nil
+}
+
+// convertArg 将 JSON 解码得到的参数或上一个任务的返回值转换为类型 t，类型不同时经 JSON 编码后解码为 t。
+// time.Duration 类型的数字表示秒数，字符串按 time.ParseDuration 解析
+func convertArg(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(t), nil
+	}
+	v := reflect.ValueOf(arg)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	if t == durationType {
+		return convertDuration(v)
+	}
+	b, err := json.Marshal(arg)
+	if err != nil {
+		return reflect.Value{}, errors.New(fmt.Sprintf("cannot convert %T to %s", arg, t))
+	}
+	ptr := reflect.New(t)
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(ptr.Interface()); err != nil {
+		return reflect.Value{}, errors.New(fmt.Sprintf("cannot convert %s to %s: %s", truncateArg(b), t, err.Error()))
+	}
+	return ptr.Elem(), nil
+}
+
+func convertDuration(v reflect.Value) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return reflect.ValueOf(time.Duration(v.Float() * float64(time.Second))), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(time.Duration(v.Int()) * time.Second), nil
+	case reflect.String:
+		d, err := time.ParseDuration(v.String())
+		if err != nil {
+			return reflect.Value{}, errors.New(fmt.Sprintf("cannot convert %q to time.Duration: %s", v.String(), err.Error()))
+		}
+		return reflect.ValueOf(d), nil
+	}
+	return reflect.Value{}, errors.New(fmt.Sprintf("cannot convert %s to time.Duration, expected seconds or a duration string", v.Type()))
+}
+
+// truncateArg 错误信息中的参数最多保留 64 字节
+func truncateArg(b []byte) string {
+	if len(b) > 64 {
+		return string(b[:64]) + "..."
+	}
+	return string(b)
+}
+
+// call 调用注册的函数，函数第一个参数为 context.Context 时传入 ctx，
+// 参数无法转换、函数 panic 或最后一个返回值为非 nil 的 error 时返回错误
+func call(ctx context.Context, funcName string, args []interface{}, kwargs map[string]interface{}) (result []reflect.Value, err error) {
+	f, err := lookupFunc(funcName)
+	if err != nil {
+		return nil, err
+	}
+	in, err := f.bind(args, kwargs, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.withContext {
+		in = append([]reflect.Value{reflect.ValueOf(ctx)}, in...)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("%s panicked: %v", funcName, r))
+		}
+	}()
+	result = f.fn.Call(in)
+	if n := len(result); n > 0 && result[n-1].Type() == errorType && !result[n-1].IsNil() {
+		err = result[n-1].Interface().(error)
+	}
+	return
+}
+
+// validateFuncArgs 提交任务时检查函数已注册且参数能转换为函数声明的类型，
+// resultArgs 指定的参数执行时由上一个任务的返回值填充，不做检查
+func validateFuncArgs(job *jobs.Job, resultArgs []jobs.ResultArg) error {
+	f, err := lookupFunc(job.FuncName)
+	if err != nil {
+		return err
+	}
+	args := append([]interface{}{}, job.Args...)
+	skip := make(map[int]bool)
+	for _, ra := range resultArgs {
+		for len(args) <= ra.Arg {
+			args = append(args, nil)
+		}
+		skip[ra.Arg] = true
+	}
+	_, err = f.bind(args, job.Kwargs, skip)
+	return err
+}
@@ -0,0 +1,177 @@
+package executors
+
+import (
+	"context"
+	"encoding/json"
+	"go-Job-Scheduler/jobs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func init() {
+	MustRegister("test.types", func(n int, f float64, s string, b bool, u uint8) {})
+	MustRegister("test.struct", func(p testPoint, pp *testPoint, list []int, m map[string]int, keys map[int]string) {})
+	MustRegister("test.time", func(at time.Time, d time.Duration) {})
+	MustRegister("test.variadic", func(prefix string, values ...int) {})
+	MustRegister("test.kwargs", func(ctx context.Context, x, y int, p testPoint) testPoint {
+		return testPoint{X: x + p.X, Y: y + p.Y}
+	})
+	MustRegister("test.any", func(v interface{}) {})
+}
+
+// decodeArgs 按提交任务时的 JSON 解码参数
+func decodeArgs(t *testing.T, args, kwargs string) ([]interface{}, map[string]interface{}) {
+	var a []interface{}
+	var k map[string]interface{}
+	if err := json.Unmarshal([]byte(args), &a); err != nil {
+		t.Fatal(err)
+	}
+	if kwargs != "" {
+		if err := json.Unmarshal([]byte(kwargs), &k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, k
+}
+
+func TestBind(t *testing.T) {
+	at := time.Date(2022, 6, 4, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		funcName string
+		args     string
+		kwargs   string
+		want     []interface{}
+		message  string
+	}{
+		{"test.types", `[1, 2.5, "s", true, 255]`, "", []interface{}{1, 2.5, "s", true, uint8(255)}, ""},
+		{"test.types", `[1.5, 2.5, "s", true, 255]`, "", nil, "arg 0"},
+		{"test.types", `[1, 2.5, "s", true, 256]`, "", nil, "arg 4"},
+		{"test.types", `[1, 2.5, 3, true, 1]`, "", nil, "arg 2"},
+		{"test.types", `[1, 2.5, "s", true]`, "", nil, "expects 5 args, got 4"},
+		// 结构体、指针、切片及 map 经 JSON 转换
+		{"test.struct", `[{"x": 1, "y": 2}, {"x": 3}, [1, 2], {"a": 1}, {"1": "one"}]`, "",
+			[]interface{}{testPoint{1, 2}, &testPoint{X: 3}, []int{1, 2}, map[string]int{"a": 1}, map[int]string{1: "one"}}, ""},
+		{"test.struct", `[{"x": 1}, null, null, null, null]`, "",
+			[]interface{}{testPoint{X: 1}, (*testPoint)(nil), []int(nil), map[string]int(nil), map[int]string(nil)}, ""},
+		{"test.struct", `[{"x": 1, "z": 2}, null, null, null, null]`, "", nil, `unknown field "z"`},
+		{"test.struct", `[{"x": 1}, null, ["a"], null, null]`, "", nil, "arg 2"},
+		// time.Time 为 RFC 3339 字符串，time.Duration 为秒数或时长字符串
+		{"test.time", `["2022-06-04T08:00:00Z", 90]`, "", []interface{}{at, 90 * time.Second}, ""},
+		{"test.time", `["2022-06-04T08:00:00Z", 1.5]`, "", []interface{}{at, 1500 * time.Millisecond}, ""},
+		{"test.time", `["2022-06-04T08:00:00Z", "1m30s"]`, "", []interface{}{at, 90 * time.Second}, ""},
+		{"test.time", `["2022-06-04T08:00:00Z", "soon"]`, "", nil, "time.Duration"},
+		{"test.time", `["2022-06-04T08:00:00Z", true]`, "", nil, "time.Duration"},
+		{"test.time", `["tomorrow", 1]`, "", nil, "arg 0"},
+		{"test.variadic", `["p"]`, "", []interface{}{"p"}, ""},
+		{"test.variadic", `["p", 1, 2, 3]`, "", []interface{}{"p", 1, 2, 3}, ""},
+		{"test.variadic", `[]`, "", nil, "at least 1 args"},
+		{"test.variadic", `["p", 1, "x"]`, "", nil, "arg 2"},
+		// kwargs 按字段名填充最后一个结构体参数
+		{"test.kwargs", `[1, 2]`, `{"x": 5, "y": 6}`, []interface{}{1, 2, testPoint{5, 6}}, ""},
+		{"test.kwargs", `[1, 2, {"x": 5}]`, "", []interface{}{1, 2, testPoint{X: 5}}, ""},
+		// 未传 kwargs 时不以零值填充漏传的参数
+		{"test.kwargs", `[1, 2]`, "", nil, "expects 3 args, got 2"},
+		{"test.kwargs", `[1, 2]`, `{}`, nil, "expects 3 args, got 2"},
+		{"test.kwargs", `[1, 2, {"x": 5}]`, `{"y": 6}`, nil, "takes kwargs in place of its last arg"},
+		{"test.kwargs", `[1, 2]`, `{"x": 5, "z": 6}`, nil, `kwargs: cannot convert`},
+		{"test.types", `[1, 2.5, "s", true, 1]`, `{"x": 1}`, nil, "does not take kwargs"},
+		{"test.any", `[{"a": [1]}]`, "", []interface{}{map[string]interface{}{"a": []interface{}{1.0}}}, ""},
+	}
+	for _, test := range tests {
+		f, err := lookupFunc(test.funcName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args, kwargs := decodeArgs(t, test.args, test.kwargs)
+		in, err := f.bind(args, kwargs, nil)
+		if test.message != "" {
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Fatalf("%s %s %s: err %v, want %q", test.funcName, test.args, test.kwargs, err, test.message)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s %s %s: %v", test.funcName, test.args, test.kwargs, err)
+		}
+		got := make([]interface{}, len(in))
+		for i, v := range in {
+			got[i] = v.Interface()
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("%s %s %s: bound %#v, want %#v", test.funcName, test.args, test.kwargs, got, test.want)
+		}
+	}
+}
+
+func TestCallKwargs(t *testing.T) {
+	args, kwargs := decodeArgs(t, `[1, 2]`, `{"x": 10, "y": 20}`)
+	values, err := call(context.Background(), "test.kwargs", args, kwargs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || values[0].Interface() != (testPoint{11, 22}) {
+		t.Fatalf("values %v", values)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	tests := []struct {
+		fn      interface{}
+		message string
+	}{
+		{"not a function", "not a function"},
+		{func(ch chan int) {}, "unsupported type"},
+		{func(s fmtStringer) {}, "unsupported interface type"},
+		{func(m map[testPoint]int) {}, "unsupported map key type"},
+		{func() (error, int) { return nil, 0 }, "error must be the last return value"},
+	}
+	for _, test := range tests {
+		if err := Register("test.invalid", test.fn); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Fatalf("%T: err %v, want %q", test.fn, err, test.message)
+		}
+	}
+	if err := Register("test.types", func() {}); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("err %v", err)
+	}
+}
+
+type fmtStringer interface {
+	String() string
+}
+
+// TestValidateJobFuncArgs /api/job/add 提交任务时以 ValidateJob 校验参数，无法转换时返回错误
+func TestValidateJobFuncArgs(t *testing.T) {
+	tests := []struct {
+		job     string
+		message string
+	}{
+		{`{"funcName": "test.kwargs", "args": [1, 2], "kwargs": {"x": 1}}`, ""},
+		{`{"funcName": "test.kwargs", "args": [1, 2, {"x": 1}]}`, ""},
+		{`{"funcName": "test.kwargs", "args": [1, 2]}`, "function test.kwargs expects 3 args, got 2"},
+		{`{"funcName": "test.kwargs", "args": [1, "two"], "kwargs": {"x": 1}}`, "function test.kwargs: arg 1: cannot convert"},
+		{`{"funcName": "test.kwargs", "args": [1, 2], "kwargs": {"w": 1}}`, `unknown field "w"`},
+		{`{"funcName": "test.missing"}`, "no such function test.missing"},
+		// 后续任务由上一个任务的返回值填充的参数不校验，其他参数仍校验
+		{`{"funcName": "test.kwargs", "args": [1, 2], "kwargs": {"x": 1},
+			"onSuccess": {"funcName": "test.struct", "args": [null, null, [1], {}, {}], "resultArgs": [{"result": 0, "arg": 0}]}}`, ""},
+		{`{"funcName": "test.kwargs", "args": [1, 2], "kwargs": {"x": 1},
+			"onSuccess": {"funcName": "test.struct", "args": [null, null, ["a"], {}, {}], "resultArgs": [{"result": 0, "arg": 0}]}}`, "arg 2"},
+	}
+	for _, test := range tests {
+		var job jobs.Job
+		if err := json.Unmarshal([]byte(test.job), &job); err != nil {
+			t.Fatal(err)
+		}
+		err := ValidateJob(job)
+		if test.message == "" && err != nil || test.message != "" && (err == nil || !strings.Contains(err.Error(), test.message)) {
+			t.Fatalf("%s: err %v, want %q", test.job, err, test.message)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package executors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io/ioutil"
+	"reflect"
+)
+
+// runGRPC 执行 grpc 类型任务，返回值为状态码名称(如 OK、Unavailable)及 JSON 格式的响应消息。
+// 方法定义从 DescriptorSet 文件或服务端反射获取，deadline 为任务的 Timeout
+func runGRPC(ctx context.Context, job jobs.Job) ([]reflect.Value, error) {
+	spec := job.GRPC
+	if spec == nil {
+		return nil, errors.New("grpc job requires a grpc spec")
+	}
+	serviceName, methodName, err := spec.ServiceMethod()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpcDial(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	method, err := grpcMethod(ctx, conn, spec, serviceName, methodName)
+	if err != nil {
+		return nil, err
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, errors.New(fmt.Sprintf("grpc method %s is not unary", method.FullName()))
+	}
+	req := dynamicpb.NewMessage(method.Input())
+	if len(spec.Request) > 0 {
+		if err = protojson.Unmarshal(spec.Request, req); err != nil {
+			return nil, errors.New(fmt.Sprintf("grpc request: %s", err.Error()))
+		}
+	}
+	resp := dynamicpb.NewMessage(method.Output())
+
+	if len(spec.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(spec.Metadata))
+	}
+	output := RunOutput(ctx)
+	fullMethod := "/" + serviceName + "/" + methodName
+	_, _ = fmt.Fprintf(output, "%s %s\n", spec.Target, fullMethod)
+	err = conn.Invoke(ctx, fullMethod, req, resp)
+	st := status.Convert(err)
+	_, _ = fmt.Fprintln(output, st.Code())
+	if err != nil {
+		if st.Message() != "" {
+			_, _ = fmt.Fprintln(output, st.Message())
+		}
+		values := []reflect.Value{reflect.ValueOf(st.Code().String()), reflect.ValueOf(json.RawMessage("null"))}
+		return values, errors.New(fmt.Sprintf("grpc status %s: %s", st.Code(), st.Message()))
+	}
+
+	body, err := protojson.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = fmt.Fprintln(output, string(body))
+	return []reflect.Value{reflect.ValueOf(st.Code().String()), reflect.ValueOf(json.RawMessage(body))}, nil
+}
+
+// grpcDialOptions 连接服务时附加的选项，测试时用于连接进程内的服务
+var grpcDialOptions []grpc.DialOption
+
+// grpcDial 按任务的 TLS 选项连接服务，未设置 TLS 时使用明文连接
+func grpcDial(ctx context.Context, spec *jobs.GRPCSpec) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if spec.TLS != nil {
+		config, err := tlsConfig(spec.TLS)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(config)
+	}
+	options := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, grpcDialOptions...)
+	return grpc.DialContext(ctx, spec.Target, options...)
+}
+
+// grpcMethod 查找方法定义，设置了 DescriptorSet 时从文件读取，否则通过服务端反射获取
+func grpcMethod(ctx context.Context, conn *grpc.ClientConn, spec *jobs.GRPCSpec, serviceName, methodName string) (protoreflect.MethodDescriptor, error) {
+	var files *protoregistry.Files
+	var err error
+	if spec.DescriptorSet != "" {
+		files, err = descriptorSetFiles(spec.DescriptorSet)
+	} else {
+		files, err = reflectionFiles(ctx, conn, serviceName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("grpc service %s not found", serviceName))
+	}
+	service, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s is not a grpc service", serviceName))
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, errors.New(fmt.Sprintf("grpc method %s not found in %s", methodName, serviceName))
+	}
+	return method, nil
+}
+
+// descriptorSetFiles 读取 FileDescriptorSet 文件
+func descriptorSetFiles(path string) (*protoregistry.Files, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err = proto.Unmarshal(data, &set); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid descriptor set %s: %s", path, err.Error()))
+	}
+	return protodesc.NewFiles(&set)
+}
+
+// reflectionFiles 通过服务端反射获取定义服务的文件及其依赖。
+// 使用 v1alpha 版本的反射服务以兼容较旧的服务端
+func reflectionFiles(ctx context.Context, conn *grpc.ClientConn, serviceName string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	fetch := func(req *rpb.ServerReflectionRequest) error {
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return errors.New(fmt.Sprintf("grpc reflection: %s", err.Error()))
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return errors.New(fmt.Sprintf("grpc reflection: %s", e.GetErrorMessage()))
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(b, file); err != nil {
+				return err
+			}
+			protos[file.GetName()] = file
+		}
+		return nil
+	}
+
+	if err = fetch(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: serviceName},
+	}); err != nil {
+		return nil, err
+	}
+	// 服务端可能只返回部分依赖，逐个获取缺少的依赖
+	for {
+		var missing []string
+		for _, file := range protos {
+			for _, dep := range file.GetDependency() {
+				if _, ok := protos[dep]; !ok {
+					missing = append(missing, dep)
+				}
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		for _, name := range missing {
+			if _, ok := protos[name]; ok {
+				continue
+			}
+			if err = fetch(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+			}); err != nil {
+				return nil, err
+			}
+			if _, ok := protos[name]; !ok {
+				return nil, errors.New(fmt.Sprintf("grpc reflection: file %s not returned", name))
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range protos {
+		set.File = append(set.File, file)
+	}
+	return protodesc.NewFiles(set)
+}
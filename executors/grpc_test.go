@@ -0,0 +1,205 @@
+package executors
+
+import (
+	"context"
+	"encoding/json"
+	"go-Job-Scheduler/jobs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// grpcCall 服务端收到的调用
+type grpcCall struct {
+	deadline time.Time
+	metadata metadata.MD
+}
+
+// grpcServer 启动进程内的 health 服务，withReflection 时同时注册反射服务，
+// 返回服务端收到的调用
+func grpcServer(t *testing.T, withReflection bool) chan grpcCall {
+	listener := bufconn.Listen(1 << 20)
+	calls := make(chan grpcCall, 10)
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		call := grpcCall{}
+		call.deadline, _ = ctx.Deadline()
+		call.metadata, _ = metadata.FromIncomingContext(ctx)
+		calls <- call
+		return handler(ctx, req)
+	}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	if withReflection {
+		reflection.Register(server)
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	grpcDialOptions = []grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})}
+	t.Cleanup(func() {
+		grpcDialOptions = nil
+		server.Stop()
+	})
+	return calls
+}
+
+func grpcJob(id string, spec jobs.GRPCSpec) jobs.Job {
+	spec.Target = "bufnet"
+	if spec.Method == "" {
+		spec.Method = "grpc.health.v1.Health/Check"
+	}
+	return jobs.Job{Id: id, Name: "check", Kind: jobs.KindGRPC, GRPC: &spec}
+}
+
+// jsonEqual 比较 JSON 解码后的值，protojson 的输出格式不稳定
+func jsonEqual(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+func TestRunGRPCReflection(t *testing.T) {
+	calls := grpcServer(t, true)
+	tests := []struct {
+		request  string
+		code     string
+		response string
+		message  string
+	}{
+		// JSON 请求按 protobuf 的 JSON 映射转换，响应转换为 JSON
+		{`{"service": "orders"}`, "OK", `{"status":"SERVING"}`, ""},
+		{``, "OK", `{"status":"SERVING"}`, ""},
+		// 非 OK 状态码作为执行结果返回
+		{`{"service": "billing"}`, "NotFound", `null`, "grpc status NotFound"},
+	}
+	for _, test := range tests {
+		job := grpcJob("grpc", jobs.GRPCSpec{Request: json.RawMessage(test.request), Metadata: map[string]string{"x-trace": "abc"}})
+		values, err := runGRPC(context.Background(), job)
+		if test.message == "" && err != nil || test.message != "" && (err == nil || !strings.Contains(err.Error(), test.message)) {
+			t.Fatalf("%s: err %v, want %q", test.request, err, test.message)
+		}
+		if len(values) != 2 || values[0].String() != test.code {
+			t.Fatalf("%s: values %v", test.request, values)
+		}
+		var got, want interface{}
+		_ = json.Unmarshal(values[1].Interface().(json.RawMessage), &got)
+		_ = json.Unmarshal([]byte(test.response), &want)
+		if !jsonEqual(got, want) {
+			t.Fatalf("%s: response %s, want %s", test.request, values[1].Interface(), test.response)
+		}
+		call := <-calls
+		if v := call.metadata.Get("x-trace"); len(v) != 1 || v[0] != "abc" {
+			t.Fatalf("%s: metadata %v", test.request, call.metadata)
+		}
+	}
+}
+
+func TestRunGRPCInvalid(t *testing.T) {
+	grpcServer(t, true)
+	tests := []struct {
+		spec    jobs.GRPCSpec
+		message string
+	}{
+		{jobs.GRPCSpec{Request: json.RawMessage(`{"bogus": 1}`)}, "grpc request"},
+		{jobs.GRPCSpec{Request: json.RawMessage(`{"service": 1}`)}, "grpc request"},
+		{jobs.GRPCSpec{Method: "grpc.health.v1.Health/Missing"}, "grpc method Missing not found"},
+		{jobs.GRPCSpec{Method: "grpc.health.v1.Nope/Check"}, "grpc reflection"},
+		// 流式方法不支持
+		{jobs.GRPCSpec{Method: "grpc.health.v1.Health/Watch"}, "not unary"},
+	}
+	for _, test := range tests {
+		_, err := runGRPC(context.Background(), grpcJob("grpc", test.spec))
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Fatalf("%s: err %v, want %q", test.spec.Method, err, test.message)
+		}
+	}
+}
+
+func TestRunGRPCDeadline(t *testing.T) {
+	calls := grpcServer(t, true)
+	ch := watch(t, "grpc/", 1)
+	executor := newTestBaseExecutor(t, ExecutorOption{PoolSize: 1, QueueSize: 1, Backpressure: BackpressureBlock})
+
+	// 任务的 Timeout 作为调用的 deadline
+	job := grpcJob("grpc/deadline", jobs.GRPCSpec{Request: json.RawMessage(`{"service": "orders"}`)})
+	job.Timeout = 5 * time.Second
+	start := time.Now()
+	if err := executor.Add(job); err != nil {
+		t.Fatal(err)
+	}
+	result := results(t, ch, 1)[0]
+	if result.Status != jobs.RunSucceeded {
+		t.Fatalf("%s: %v", result.Status, result.Err)
+	}
+	call := <-calls
+	if call.deadline.IsZero() || call.deadline.Before(start.Add(job.Timeout-time.Second)) || call.deadline.After(time.Now().Add(job.Timeout)) {
+		t.Fatalf("deadline %s, started at %s with timeout %s", call.deadline, start, job.Timeout)
+	}
+}
+
+func TestRunGRPCDescriptorSet(t *testing.T) {
+	// 没有反射服务时从 descriptor set 文件读取方法定义
+	calls := grpcServer(t, false)
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto),
+	}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "health.protoset")
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := runGRPC(context.Background(), grpcJob("grpc", jobs.GRPCSpec{DescriptorSet: path, Request: json.RawMessage(`{"service": "orders"}`)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response interface{}
+	_ = json.Unmarshal(values[1].Interface().(json.RawMessage), &response)
+	if values[0].String() != "OK" || !jsonEqual(response, map[string]interface{}{"status": "SERVING"}) {
+		t.Fatalf("values %v %s", values[0], values[1].Interface())
+	}
+	<-calls
+
+	// 未设置 descriptor set 时需要反射服务
+	if _, err = runGRPC(context.Background(), grpcJob("grpc", jobs.GRPCSpec{})); err == nil {
+		t.Fatal("called without reflection or a descriptor set")
+	}
+
+	invalid := filepath.Join(dir, "invalid.protoset")
+	if err = ioutil.WriteFile(invalid, []byte("not a descriptor set"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ path, message string }{
+		{invalid, "invalid descriptor set"},
+		{filepath.Join(dir, "missing.protoset"), "no such file"},
+	} {
+		if _, err = descriptorSetFiles(test.path); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Fatalf("%s: err %v, want %q", test.path, err, test.message)
+		}
+	}
+	// 服务未包含在 descriptor set 中
+	_, err = runGRPC(context.Background(), grpcJob("grpc", jobs.GRPCSpec{DescriptorSet: path, Method: "orders.v1.OrderService/Expire"}))
+	if err == nil || !strings.Contains(err.Error(), "grpc service orders.v1.OrderService not found") {
+		t.Fatalf("err %v", err)
+	}
+}
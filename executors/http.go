@@ -0,0 +1,120 @@
+package executors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// HTTPBodySnippetSize 执行结果中保存的响应体最大字节数
+const HTTPBodySnippetSize = 4 << 10
+
+// httpBodyData 请求体模板可引用的数据
+type httpBodyData struct {
+	Job           jobs.Job
+	Now           time.Time
+	ScheduledTime time.Time
+}
+
+// runHTTP 执行 http 类型任务，返回值为响应状态码及响应体片段。
+// 状态码不在 ExpectedStatus 中时执行失败，设置了 RetryStatus 时按其决定是否重试
+func runHTTP(ctx context.Context, job jobs.Job) ([]reflect.Value, error) {
+	spec := job.HTTP
+	if spec == nil {
+		return nil, errors.New("http job requires an http spec")
+	}
+	body, err := httpBody(job)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(spec.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, spec.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range spec.Headers {
+		req.Header.Set(name, value)
+	}
+	// Host 请求头需设置在请求上
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	transport, err := httpTransport(spec.TLS)
+	if err != nil {
+		return nil, err
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	output := RunOutput(ctx)
+	_, _ = fmt.Fprintf(output, "%s %s\n", method, spec.URL)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	snippet, err := ioutil.ReadAll(io.LimitReader(resp.Body, HTTPBodySnippetSize))
+	if err != nil {
+		return nil, err
+	}
+	_, _ = fmt.Fprintln(output, resp.Status)
+	_, _ = output.Write(snippet)
+	if len(snippet) > 0 && snippet[len(snippet)-1] != '\n' {
+		_, _ = fmt.Fprintln(output)
+	}
+
+	values := []reflect.Value{reflect.ValueOf(resp.StatusCode), reflect.ValueOf(string(snippet))}
+	if spec.Expected(resp.StatusCode) {
+		return values, nil
+	}
+	err = errors.New(fmt.Sprintf("unexpected http status %d", resp.StatusCode))
+	if retry, decided := spec.ShouldRetry(resp.StatusCode); decided {
+		err = &jobs.RetryableError{Err: err, Retry: retry}
+	}
+	return values, err
+}
+
+// httpBody 按任务定义的模板生成请求体
+func httpBody(job jobs.Job) (io.Reader, error) {
+	if job.HTTP.Body == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("body").Parse(job.HTTP.Body)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid http body template: %s", err.Error()))
+	}
+	var buf bytes.Buffer
+	data := httpBodyData{Job: job, Now: time.Now(), ScheduledTime: job.ScheduledTime()}
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return nil, errors.New(fmt.Sprintf("http body template: %s", err.Error()))
+	}
+	return &buf, nil
+}
+
+// httpTransport 按任务的 TLS 选项创建 transport，未设置时使用默认配置
+func httpTransport(spec *jobs.TLSSpec) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if spec == nil {
+		return transport, nil
+	}
+	config, err := tlsConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = config
+	return transport, nil
+}
@@ -0,0 +1,203 @@
+package executors
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"go-Job-Scheduler/jobs"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func httpJob(spec jobs.HTTPSpec) jobs.Job {
+	return jobs.Job{Id: "http", Name: "ping", Kind: jobs.KindHTTP, HTTP: &spec}
+}
+
+func statusServer(t *testing.T, status int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("pong"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunHTTPExpectedStatus(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected []int
+		ok       bool
+	}{
+		{http.StatusOK, nil, true},
+		{http.StatusNoContent, nil, true},
+		{http.StatusAccepted, []int{200, 202}, true},
+		{http.StatusCreated, []int{200, 202}, false},
+		{http.StatusNotFound, []int{404}, true},
+		{http.StatusInternalServerError, nil, false},
+	}
+	for _, test := range tests {
+		server := statusServer(t, test.status)
+		values, err := runHTTP(context.Background(), httpJob(jobs.HTTPSpec{URL: server.URL, ExpectedStatus: test.expected}))
+		if (err == nil) != test.ok {
+			t.Fatalf("status %d, expected %v: err %v", test.status, test.expected, err)
+		}
+		if len(values) != 2 || values[0].Int() != int64(test.status) {
+			t.Fatalf("status %d: values %v", test.status, values)
+		}
+		if test.status != http.StatusNoContent && values[1].String() != "pong" {
+			t.Fatalf("status %d: body %q", test.status, values[1].String())
+		}
+	}
+}
+
+func TestRunHTTPRetryStatus(t *testing.T) {
+	tests := []struct {
+		status      int
+		retryStatus []int
+		decided     bool
+		retry       bool
+	}{
+		// 未设置 retryStatus 时由任务的重试策略决定
+		{http.StatusServiceUnavailable, nil, false, false},
+		{http.StatusServiceUnavailable, []int{429, 503}, true, true},
+		{http.StatusTooManyRequests, []int{429, 503}, true, true},
+		{http.StatusBadRequest, []int{429, 503}, true, false},
+	}
+	for _, test := range tests {
+		server := statusServer(t, test.status)
+		_, err := runHTTP(context.Background(), httpJob(jobs.HTTPSpec{URL: server.URL, RetryStatus: test.retryStatus}))
+		if err == nil {
+			t.Fatalf("status %d: expected an error", test.status)
+		}
+		var retryable *jobs.RetryableError
+		if errors.As(err, &retryable) != test.decided {
+			t.Fatalf("status %d, retry status %v: err %#v", test.status, test.retryStatus, err)
+		}
+		if test.decided && retryable.Retry != test.retry {
+			t.Fatalf("status %d, retry status %v: retry %v", test.status, test.retryStatus, retryable.Retry)
+		}
+	}
+}
+
+func TestRunHTTPBodyTemplate(t *testing.T) {
+	type request struct {
+		method, contentType, host, body string
+	}
+	received := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- request{r.Method, r.Header.Get("Content-Type"), r.Host, string(body)}
+	}))
+	defer server.Close()
+
+	job := httpJob(jobs.HTTPSpec{
+		Method:  "post",
+		URL:     server.URL,
+		Headers: map[string]string{"Content-Type": "application/json", "Host": "hooks.example.com"},
+		Body:    `{"job": "{{.Job.Name}}", "time": "{{.ScheduledTime.Format "2006-01-02T15:04:05Z07:00"}}"}`,
+	})
+	job.SetScheduledTime(time.Date(2022, 6, 4, 10, 0, 0, 0, time.UTC))
+	if _, err := runHTTP(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	r := <-received
+	if r.method != http.MethodPost || r.contentType != "application/json" || r.host != "hooks.example.com" {
+		t.Fatalf("request %+v", r)
+	}
+	if want := `{"job": "ping", "time": "2022-06-04T10:00:00Z"}`; r.body != want {
+		t.Fatalf("body %s, want %s", r.body, want)
+	}
+
+	job.HTTP.Body = `{{.Missing}}`
+	if _, err := runHTTP(context.Background(), job); err == nil {
+		t.Fatal("expected a template error")
+	}
+}
+
+// writePEM 将 PEM 块写入临时目录中的文件并返回路径
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCert 生成自签名的客户端证书，返回证书及私钥文件
+func clientCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "scheduler"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func TestRunHTTPTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	certFile, keyFile := clientCert(t)
+	missing := filepath.Join(os.TempDir(), "no-such-ca.pem")
+	tests := []struct {
+		name   string
+		tls    *jobs.TLSSpec
+		ok     bool
+		status int64
+	}{
+		{"system CA", nil, false, 0},
+		{"ca file", &jobs.TLSSpec{CAFile: caFile}, true, http.StatusUnauthorized},
+		{"server name", &jobs.TLSSpec{CAFile: caFile, ServerName: "example.com"}, true, http.StatusUnauthorized},
+		{"wrong server name", &jobs.TLSSpec{CAFile: caFile, ServerName: "wrong.example.org"}, false, 0},
+		{"missing ca file", &jobs.TLSSpec{CAFile: missing}, false, 0},
+		{"insecure", &jobs.TLSSpec{InsecureSkipVerify: true}, true, http.StatusUnauthorized},
+		{"client cert", &jobs.TLSSpec{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, true, http.StatusOK},
+	}
+	for _, test := range tests {
+		values, err := runHTTP(context.Background(), httpJob(jobs.HTTPSpec{URL: server.URL, TLS: test.tls, ExpectedStatus: []int{200, 401}}))
+		if (err == nil) != test.ok {
+			t.Fatalf("%s: err %v", test.name, err)
+		}
+		if test.ok && values[0].Int() != test.status {
+			t.Fatalf("%s: status %d, want %d", test.name, values[0].Int(), test.status)
+		}
+		if test.status == http.StatusOK && values[1].String() != "scheduler" {
+			t.Fatalf("%s: server saw client cert %q", test.name, values[1].String())
+		}
+	}
+}
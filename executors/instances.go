@@ -0,0 +1,85 @@
+package executors
+
+import (
+	"go-Job-Scheduler/jobs"
+	"log"
+	"sync"
+)
+
+// instances 记录每个任务正在运行的实例数，以及因达到最大实例数而排队的执行，
+// 均按原任务 id 索引，重试任务计入原任务的实例数
+type instances struct {
+	instancesMu sync.Mutex
+	// running 每个任务正在运行的实例数
+	running map[string]int
+	// pending 每个任务因达到最大实例数而排队的执行
+	pending map[string][]jobs.Job
+}
+
+func newInstances() *instances {
+	return &instances{
+		running: make(map[string]int),
+		pending: make(map[string][]jobs.Job),
+	}
+}
+
+// acquire 检查任务正在运行的实例数，未达上限时占用一个实例；
+// 达到上限时按任务的 InstancePolicy 跳过或排队
+func (this *instances) acquire(job jobs.Job) bool {
+	this.instancesMu.Lock()
+	defer this.instancesMu.Unlock()
+
+	key := job.OriginalId()
+	if this.running[key] < job.InstanceLimit() {
+		this.running[key]++
+		return true
+	}
+	if job.InstancePolicy != jobs.InstanceQueue {
+		log.Println("Skipped job", job.Id, ": max instances reached")
+		recordEvent(job.Id, EventSkipped, "skipped: max instances")
+		return false
+	}
+	// 合并排队中的多次执行为一次
+	if job.Coalesce && len(this.pending[key]) > 0 {
+		recordEvent(job.Id, EventCoalesced, "coalesced with a pending run")
+		return false
+	}
+	this.pending[key] = append(this.pending[key], job)
+	recordEvent(job.Id, EventQueued, "queued: max instances")
+	return false
+}
+
+// release 释放任务的一个运行实例，若有排队的执行则取出一个
+func (this *instances) release(job jobs.Job) (jobs.Job, bool) {
+	this.instancesMu.Lock()
+	defer this.instancesMu.Unlock()
+
+	key := job.OriginalId()
+	if queue := this.pending[key]; len(queue) > 0 {
+		next := queue[0]
+		if len(queue) == 1 {
+			delete(this.pending, key)
+		} else {
+			this.pending[key] = queue[1:]
+		}
+		// 实例直接交给排队的执行，运行数不变
+		return next, true
+	}
+	this.running[key]--
+	if this.running[key] <= 0 {
+		delete(this.running, key)
+	}
+	return jobs.Job{}, false
+}
+
+// dropPending 丢弃任务(含其重试)排队中的执行，jobId 为原任务 id，为空时丢弃所有任务的
+func (this *instances) dropPending(jobId string) {
+	this.instancesMu.Lock()
+	defer this.instancesMu.Unlock()
+
+	if jobId == "" {
+		this.pending = make(map[string][]jobs.Job)
+		return
+	}
+	delete(this.pending, jobId)
+}
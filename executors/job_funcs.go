@@ -1,12 +1,28 @@
 package executors
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
-func DoAdd(x, y float64) int {
-	fmt.Printf("DoAdd: %d + %d\n", int(x), int(y))
-	return int(x) + int(y)
+func DoAdd(ctx context.Context, x, y int) int {
+	Logger(ctx).Printf("DoAdd: %d + %d", x, y)
+	return x + y
 }
 
-func DoPrint(v ...interface{}) {
-	fmt.Println(v...)
+// DoPrint 输出到本次执行的输出中
+func DoPrint(ctx context.Context, v ...interface{}) {
+	_, _ = fmt.Fprintln(RunOutput(ctx), v...)
+}
+
+// DoSleep 等待指定时长(秒数或 "1m30s" 格式)，超时或被取消时提前返回
+func DoSleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		Logger(ctx).Printf("DoSleep: slept %v", d)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,700 @@
+package executors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go-Job-Scheduler/jobs"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLeaseTimeout worker 未续租时重新分发执行的默认时间
+	DefaultLeaseTimeout = 30 * time.Second
+	// MaxPollWait worker 长轮询的最长等待时间，api 另按 web server 的写超时限制
+	MaxPollWait = 30 * time.Second
+	// MaxLeaseDispatches 一次执行因租约过期最多分发的次数，超过后视为执行失败，
+	// 避免导致 worker 崩溃的任务被不断重新分发
+	MaxLeaseDispatches = 3
+)
+
+var (
+	ErrUnknownWorker = errors.New("unknown worker, register again")
+	ErrLeaseNotFound = errors.New("lease not found or expired")
+)
+
+// WorkerRegistration worker 注册时上报的信息及可执行的任务
+type WorkerRegistration struct {
+	// WorkerId 为空时由调度器分配，重新注册时沿用
+	WorkerId string `json:"workerId"`
+	Name     string `json:"name"`
+	// Funcs 可执行的 func 类型任务的函数名
+	Funcs []string `json:"funcs"`
+	// Kinds 可执行的任务类型，见 jobs.KindFunc 等
+	Kinds []string `json:"kinds"`
+	// Labels worker 的标签，只领取所要求的标签均一致的任务，见 jobs.Job.Labels
+	Labels map[string]string `json:"labels"`
+	// Queues 领取任务的队列，为空时领取所有队列的任务
+	Queues []string `json:"queues"`
+}
+
+// WorkerRegistered 注册结果，worker 需在 LeaseTimeout 秒内为正在执行的租约续租
+type WorkerRegistered struct {
+	WorkerId     string  `json:"workerId"`
+	LeaseTimeout float64 `json:"leaseTimeout"`
+}
+
+// PollRequest worker 领取执行的长轮询请求
+type PollRequest struct {
+	WorkerId string `json:"workerId"`
+	// Wait 没有可执行的任务时等待的秒数，最长 MaxPollWait
+	Wait float64 `json:"wait"`
+}
+
+// Lease 分发给 worker 的一次执行，worker 需在到期前续租并上报结果
+type Lease struct {
+	Id      string    `json:"leaseId"`
+	RunId   string    `json:"runId"`
+	Job     jobs.Job  `json:"job"`
+	Expires time.Time `json:"expires"`
+}
+
+// Heartbeat worker 定期为正在执行的租约续租，并上报新的输出
+type Heartbeat struct {
+	WorkerId string   `json:"workerId"`
+	Leases   []string `json:"leases"`
+	// Output 各租约自上次上报后的新输出
+	Output map[string]string `json:"output"`
+}
+
+// HeartbeatReply Cancel 为 worker 应停止执行的租约，如任务被删除或租约已过期
+type HeartbeatReply struct {
+	Cancel []string `json:"cancel"`
+}
+
+// LeaseResult worker 上报的执行结果
+type LeaseResult struct {
+	WorkerId  string    `json:"workerId"`
+	LeaseId   string    `json:"leaseId"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error"`
+	// Retryable 由执行方决定是否重试，见 jobs.RetryableError
+	Retryable *bool         `json:"retryable,omitempty"`
+	Values    []interface{} `json:"values"`
+	// Output 自上次心跳后的新输出
+	Output          string `json:"output"`
+	OutputTruncated bool   `json:"outputTruncated"`
+}
+
+// WorkerInfo 已注册的 worker 及其正在执行的租约数
+type WorkerInfo struct {
+	WorkerRegistration
+	LastSeen time.Time `json:"lastSeen"`
+	Leases   int       `json:"leases"`
+}
+
+// LeaseInfo 正在执行的租约
+type LeaseInfo struct {
+	Id         string    `json:"leaseId"`
+	RunId      string    `json:"runId"`
+	JobId      string    `json:"jobId"`
+	Queue      string    `json:"queue"`
+	WorkerId   string    `json:"workerId"`
+	StartTime  time.Time `json:"startTime"`
+	Expires    time.Time `json:"expires"`
+	Dispatches int       `json:"dispatches"`
+	Cancelled  bool      `json:"cancelled"`
+}
+
+// QueueInfo 队列等待分发及正在执行的任务数，Limit 为 0 表示不限，Workers 为领取该队列任务的 worker 数
+type QueueInfo struct {
+	Name    string `json:"name"`
+	Queued  int    `json:"queued"`
+	Leased  int    `json:"leased"`
+	Limit   int    `json:"limit"`
+	Workers int    `json:"workers"`
+}
+
+// RemoteStatus 远程执行器的 worker、租约及等待分发的执行数
+type RemoteStatus struct {
+	Workers []WorkerInfo `json:"workers"`
+	Leases  []LeaseInfo  `json:"leases"`
+	Queued  int          `json:"queued"`
+}
+
+// RemoteExecutor 将执行分发给远程 worker 进程。worker 通过 HTTP 长轮询领取可执行的任务，
+// 定期续租并上报结果；未按时续租的执行重新分发给其他 worker
+type RemoteExecutor struct {
+	// QueueSize 等待分发的队列长度，Backpressure 队列已满时的处理方式
+	QueueSize    int
+	Backpressure string
+	// RunLogSize 每次执行保存的最大输出字节数
+	RunLogSize int
+	// LeaseTimeout worker 未续租时重新分发执行的时间
+	LeaseTimeout time.Duration
+	// QueueLimits 每个队列同时执行的最大任务数
+	QueueLimits map[string]int
+	startOnce   sync.Once
+	mu          sync.Mutex
+	*instances
+	// queue 等待分发的执行
+	queue []dispatch
+	// changed 队列、租约或 worker 变化时关闭并替换，唤醒等待中的 Poll 及 Add
+	changed chan struct{}
+	workers map[string]*WorkerInfo
+	leases  map[string]*lease
+	// leased 每个队列正在执行的租约数
+	leased map[string]int
+	// ctx Shutdown 时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	reaper sync.WaitGroup
+}
+
+// dispatch 等待分发的一次执行，acquired 表示已占用运行实例，如租约过期后重新分发的执行
+type dispatch struct {
+	job        jobs.Job
+	acquired   bool
+	dispatches int
+}
+
+// lease 分发给 worker 的一次执行
+type lease struct {
+	Lease
+	workerId   string
+	start      time.Time
+	runLog     *RunLog
+	dispatches int
+	// cancelled 任务已被取消，worker 下次心跳时停止执行
+	cancelled bool
+}
+
+// setOption 设置队列及租约时间，并启动检查租约过期的 goroutine
+func (this *RemoteExecutor) setOption(option ExecutorOption) {
+	this.startOnce.Do(func() {
+		this.QueueSize = option.QueueSize
+		if this.QueueSize <= 0 {
+			this.QueueSize = DefaultQueueSize
+		}
+		this.Backpressure = option.Backpressure
+		switch this.Backpressure {
+		case BackpressureBlock, BackpressureReject, BackpressureDefer:
+		default:
+			log.Println("Error: invalid executor backpressure", this.Backpressure, ", using", BackpressureBlock)
+			this.Backpressure = BackpressureBlock
+		}
+		this.RunLogSize = option.RunLogSize
+		if this.RunLogSize <= 0 {
+			this.RunLogSize = DefaultRunLogSize
+		}
+		this.LeaseTimeout = option.LeaseTimeout
+		if this.LeaseTimeout <= 0 {
+			this.LeaseTimeout = DefaultLeaseTimeout
+		}
+		this.QueueLimits = make(map[string]int)
+		for queue, limit := range option.QueueLimits {
+			if limit > 0 {
+				this.QueueLimits[queue] = limit
+			}
+		}
+		SetShellAllowList(option.ShellAllowList, option.ShellEnvAllowList, option.ShellDirAllowList)
+		this.reaper.Add(1)
+		go this.reap()
+	})
+}
+
+// broadcast 唤醒等待队列或租约变化的调用方，需持有 mu
+func (this *RemoteExecutor) broadcast() {
+	close(this.changed)
+	this.changed = make(chan struct{})
+}
+
+// Add 将任务放入分发队列。队列已满时按 Backpressure 阻塞等待，
+// 或返回 ErrRejected(丢弃本次执行) / ErrDeferred(由调用方稍后重新提交)
+func (this *RemoteExecutor) Add(job jobs.Job) error {
+	return this.add(job, this.Backpressure == BackpressureBlock)
+}
+
+// TryAdd 将任务放入分发队列，队列已满时不阻塞，Backpressure 为 block 时也返回 ErrDeferred
+func (this *RemoteExecutor) TryAdd(job jobs.Job) error {
+	return this.add(job, false)
+}
+
+func (this *RemoteExecutor) add(job jobs.Job, block bool) error {
+	this.mu.Lock()
+	for len(this.queue) >= this.QueueSize && this.ctx.Err() == nil {
+		if this.Backpressure == BackpressureReject {
+			this.mu.Unlock()
+			log.Println("Rejected job", job.Id, ": executor queue is full")
+			recordEvent(job.Id, EventRejected, "rejected: executor queue is full")
+			return ErrRejected
+		}
+		if !block {
+			this.mu.Unlock()
+			if this.Backpressure == BackpressureDefer {
+				recordEvent(job.Id, EventDeferred, "deferred: executor queue is full")
+			}
+			return ErrDeferred
+		}
+		changed := this.changed
+		this.mu.Unlock()
+		select {
+		case <-changed:
+		case <-this.ctx.Done():
+		}
+		this.mu.Lock()
+	}
+	defer this.mu.Unlock()
+	if this.ctx.Err() != nil {
+		return ErrShutdown
+	}
+	this.queue = append(this.queue, dispatch{job: job})
+	this.broadcast()
+	return nil
+}
+
+// Register 注册 worker 及其可执行的任务，已注册的 worker 重新注册时更新
+func (this *RemoteExecutor) Register(registration WorkerRegistration) WorkerRegistered {
+	if registration.WorkerId == "" {
+		registration.WorkerId = uuid.New().String()
+	}
+	this.mu.Lock()
+	this.workers[registration.WorkerId] = &WorkerInfo{WorkerRegistration: registration, LastSeen: time.Now()}
+	this.broadcast()
+	this.mu.Unlock()
+
+	log.Println("Worker", registration.WorkerId, registration.Name, "registered, kinds", registration.Kinds, "funcs", registration.Funcs,
+		"labels", registration.Labels, "queues", registration.Queues)
+	return WorkerRegistered{WorkerId: registration.WorkerId, LeaseTimeout: this.LeaseTimeout.Seconds()}
+}
+
+// Poll 为 worker 领取一个可执行的任务，没有时最多等待 wait，超时或 ctx 取消时返回 nil
+func (this *RemoteExecutor) Poll(ctx context.Context, workerId string, wait time.Duration) (*Lease, error) {
+	if wait > MaxPollWait {
+		wait = MaxPollWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		this.mu.Lock()
+		if this.ctx.Err() != nil {
+			this.mu.Unlock()
+			return nil, ErrShutdown
+		}
+		worker, ok := this.workers[workerId]
+		if !ok {
+			this.mu.Unlock()
+			return nil, ErrUnknownWorker
+		}
+		worker.LastSeen = time.Now()
+		l := this.take(worker)
+		changed := this.changed
+		this.mu.Unlock()
+		if l != nil {
+			log.Println("Leased job", l.Job.Id, "to worker", workerId)
+			return l, nil
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, nil
+		case <-this.ctx.Done():
+			return nil, ErrShutdown
+		}
+	}
+}
+
+// take 取出 worker 可执行且所在队列未达到并发上限的第一个任务并创建租约，需持有 mu。
+// 达到最大实例数的执行按任务的 InstancePolicy 跳过或排队
+func (this *RemoteExecutor) take(worker *WorkerInfo) *Lease {
+	for i := 0; i < len(this.queue); {
+		item := this.queue[i]
+		if !worker.supports(item.job) || this.queueFull(item.job.QueueName()) {
+			i++
+			continue
+		}
+		this.queue = append(this.queue[:i:i], this.queue[i+1:]...)
+		this.broadcast()
+		if !item.acquired && !this.acquire(item.job) {
+			continue
+		}
+
+		now := time.Now()
+		l := &lease{
+			Lease: Lease{
+				Id:      uuid.New().String(),
+				RunId:   uuid.New().String(),
+				Job:     item.job,
+				Expires: now.Add(this.LeaseTimeout),
+			},
+			workerId:   worker.WorkerId,
+			start:      now,
+			runLog:     newRunLog(item.job.OriginalId(), this.RunLogSize),
+			dispatches: item.dispatches + 1,
+		}
+		this.leases[l.Id] = l
+		this.leased[item.job.QueueName()]++
+		registerRunLog(l.RunId, l.runLog)
+		result := l.Lease
+		return &result
+	}
+	return nil
+}
+
+// queueFull 队列正在执行的任务数是否达到并发上限，需持有 mu
+func (this *RemoteExecutor) queueFull(queue string) bool {
+	limit, ok := this.QueueLimits[queue]
+	return ok && this.leased[queue] >= limit
+}
+
+// removeLease 删除租约并减少所在队列正在执行的任务数，需持有 mu
+func (this *RemoteExecutor) removeLease(l *lease) {
+	delete(this.leases, l.Id)
+	queue := l.Job.QueueName()
+	if this.leased[queue]--; this.leased[queue] <= 0 {
+		delete(this.leased, queue)
+	}
+}
+
+// supports 判断 worker 能否执行任务：领取任务所在的队列，支持任务类型及函数，且具有任务要求的标签
+func (worker *WorkerInfo) supports(job jobs.Job) bool {
+	if len(worker.Queues) > 0 && !containsString(worker.Queues, job.QueueName()) {
+		return false
+	}
+	if !job.MatchLabels(worker.Labels) {
+		return false
+	}
+	if !containsString(worker.Kinds, job.KindOf()) {
+		return false
+	}
+	return job.KindOf() != jobs.KindFunc || containsString(worker.Funcs, job.FuncName)
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Heartbeat 为 worker 正在执行的租约续租并追加输出，返回 worker 应停止执行的租约
+func (this *RemoteExecutor) Heartbeat(heartbeat Heartbeat) (HeartbeatReply, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	var reply HeartbeatReply
+	worker, ok := this.workers[heartbeat.WorkerId]
+	if !ok {
+		return reply, ErrUnknownWorker
+	}
+	now := time.Now()
+	worker.LastSeen = now
+	for _, id := range heartbeat.Leases {
+		l, ok := this.leases[id]
+		if !ok || l.workerId != heartbeat.WorkerId {
+			// 租约已过期并被重新分发
+			reply.Cancel = append(reply.Cancel, id)
+			continue
+		}
+		l.Expires = now.Add(this.LeaseTimeout)
+		if output := heartbeat.Output[id]; output != "" {
+			_, _ = l.runLog.Write([]byte(output))
+		}
+		if l.cancelled {
+			reply.Cancel = append(reply.Cancel, id)
+		}
+	}
+	return reply, nil
+}
+
+// Complete 记录 worker 上报的执行结果，租约已过期时返回 ErrLeaseNotFound
+func (this *RemoteExecutor) Complete(result LeaseResult) error {
+	this.mu.Lock()
+	if this.ctx.Err() != nil {
+		this.mu.Unlock()
+		return ErrShutdown
+	}
+	l, ok := this.leases[result.LeaseId]
+	if !ok || l.workerId != result.WorkerId {
+		this.mu.Unlock()
+		return ErrLeaseNotFound
+	}
+	this.removeLease(l)
+	if worker, ok := this.workers[result.WorkerId]; ok {
+		worker.LastSeen = time.Now()
+	}
+	this.broadcast()
+	this.mu.Unlock()
+	this.releaseInstance(l.Job)
+
+	if result.Output != "" {
+		_, _ = l.runLog.Write([]byte(result.Output))
+	}
+	if result.OutputTruncated {
+		l.runLog.markTruncated()
+	}
+	l.runLog.close()
+
+	status := result.Status
+	switch status {
+	case jobs.RunSucceeded, jobs.RunFailed, jobs.RunTimedOut, jobs.RunCancelled:
+	default:
+		status = jobs.RunFailed
+	}
+	var err error
+	if status != jobs.RunSucceeded {
+		message := result.Error
+		if message == "" {
+			message = status
+		}
+		err = errors.New(message)
+		if result.Retryable != nil {
+			err = &jobs.RetryableError{Err: err, Retry: *result.Retryable}
+		}
+		log.Println("Error:", l.Job.Id, err)
+	}
+	values := make([]reflect.Value, len(result.Values))
+	for i, v := range result.Values {
+		// JSON 中的 null(如返回 nil 的 error)以 interface{} 类型的零值表示
+		if v == nil {
+			values[i] = reflect.Zero(interfaceType)
+			continue
+		}
+		values[i] = reflect.ValueOf(v)
+	}
+	start, end := result.StartTime, result.EndTime
+	if start.IsZero() || end.IsZero() {
+		start, end = l.start, time.Now()
+	}
+	log.Println("Executing job", l.Job.Id, ". Done", status, "on worker", result.WorkerId)
+	this.finish(l, Result{RunId: l.RunId, Job: l.Job, StartTime: start, EndTime: end, Values: values, Status: status, Err: err, Output: l.runLog})
+	return nil
+}
+
+// finish 通知执行结果，执行成功时提交 onSuccess 后续任务
+func (this *RemoteExecutor) finish(l *lease, result Result) {
+	notify(result)
+	// 执行记录保存后不再作为正在执行的输出
+	unregisterRunLog(l.RunId)
+	if result.Status == jobs.RunSucceeded && result.Job.OnSuccess != nil {
+		chain(this, result.Job, result.Values)
+	}
+}
+
+// releaseInstance 释放任务的一个运行实例，排队中的执行放到分发队列最前面
+func (this *RemoteExecutor) releaseInstance(job jobs.Job) {
+	next, ok := this.release(job)
+	if !ok {
+		return
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.queue = append([]dispatch{{job: next, acquired: true}}, this.queue...)
+	this.broadcast()
+}
+
+// reap 定期检查过期的租约及离线的 worker，直到 Shutdown
+func (this *RemoteExecutor) reap() {
+	defer this.reaper.Done()
+	ticker := time.NewTicker(this.LeaseTimeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this.ctx.Done():
+			return
+		case now := <-ticker.C:
+			this.expire(now)
+		}
+	}
+}
+
+// expire 重新分发租约已过期的执行，被取消或分发次数达到上限的执行视为结束；
+// 超过两个租约时间未续租的 worker 视为离线
+func (this *RemoteExecutor) expire(now time.Time) {
+	this.mu.Lock()
+	var expired []*lease
+	for _, l := range this.leases {
+		if now.Before(l.Expires) {
+			continue
+		}
+		this.removeLease(l)
+		expired = append(expired, l)
+		if !l.cancelled && l.dispatches < MaxLeaseDispatches {
+			this.queue = append([]dispatch{{job: l.Job, acquired: true, dispatches: l.dispatches}}, this.queue...)
+		}
+	}
+	for id, worker := range this.workers {
+		if now.Sub(worker.LastSeen) > 2*this.LeaseTimeout {
+			delete(this.workers, id)
+			log.Println("Worker", id, worker.Name, "went away, last seen", worker.LastSeen)
+		}
+	}
+	if len(expired) > 0 {
+		this.broadcast()
+	}
+	this.mu.Unlock()
+
+	for _, l := range expired {
+		message := fmt.Sprintf("lease expired on worker %s", l.workerId)
+		_, _ = fmt.Fprintf(l.runLog, "\n... %s\n", message)
+		l.runLog.close()
+		if !l.cancelled && l.dispatches < MaxLeaseDispatches {
+			log.Println("Lease of job", l.Job.Id, "expired on worker", l.workerId, ", redispatching")
+			recordEvent(l.Job.Id, EventLeaseExpired, message+", redispatched")
+			unregisterRunLog(l.RunId)
+			continue
+		}
+		// 不再分发，按执行失败或取消处理
+		this.releaseInstance(l.Job)
+		result := Result{RunId: l.RunId, Job: l.Job, StartTime: l.start, EndTime: now, Status: jobs.RunCancelled, Err: errors.New("cancelled"), Output: l.runLog}
+		if !l.cancelled {
+			recordEvent(l.Job.Id, EventLeaseExpired, fmt.Sprintf("%s, dispatched %d times, giving up", message, l.dispatches))
+			result.Status = jobs.RunFailed
+			result.Err = errors.New(fmt.Sprintf("%s, dispatched %d times", message, l.dispatches))
+		}
+		log.Println("Error:", l.Job.Id, result.Err)
+		this.finish(l, result)
+	}
+}
+
+// Status 返回已注册的 worker、正在执行的租约及等待分发的执行数
+func (this *RemoteExecutor) Status() RemoteStatus {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	status := RemoteStatus{Workers: []WorkerInfo{}, Leases: []LeaseInfo{}, Queued: len(this.queue)}
+	counts := make(map[string]int)
+	for _, l := range this.leases {
+		counts[l.workerId]++
+		status.Leases = append(status.Leases, LeaseInfo{
+			Id:         l.Id,
+			RunId:      l.RunId,
+			JobId:      l.Job.Id,
+			Queue:      l.Job.QueueName(),
+			WorkerId:   l.workerId,
+			StartTime:  l.start,
+			Expires:    l.Expires,
+			Dispatches: l.dispatches,
+			Cancelled:  l.cancelled,
+		})
+	}
+	for _, worker := range this.workers {
+		info := *worker
+		info.Leases = counts[worker.WorkerId]
+		status.Workers = append(status.Workers, info)
+	}
+	sort.Slice(status.Workers, func(i, j int) bool { return status.Workers[i].Name < status.Workers[j].Name })
+	sort.Slice(status.Leases, func(i, j int) bool { return status.Leases[i].StartTime.Before(status.Leases[j].StartTime) })
+	return status
+}
+
+// Queues 返回各队列等待分发及正在执行的任务数，包括设置了并发上限、有任务或被 worker 领取的队列
+func (this *RemoteExecutor) Queues() []QueueInfo {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	queues := make(map[string]*QueueInfo)
+	queueInfo := func(name string) *QueueInfo {
+		info, ok := queues[name]
+		if !ok {
+			info = &QueueInfo{Name: name, Limit: this.QueueLimits[name]}
+			queues[name] = info
+		}
+		return info
+	}
+	queueInfo(jobs.DefaultQueue)
+	for name := range this.QueueLimits {
+		queueInfo(name)
+	}
+	for _, item := range this.queue {
+		queueInfo(item.job.QueueName()).Queued++
+	}
+	for name, n := range this.leased {
+		queueInfo(name).Leased = n
+	}
+	for _, worker := range this.workers {
+		for _, name := range worker.Queues {
+			queueInfo(name)
+		}
+	}
+	result := make([]QueueInfo, 0, len(queues))
+	for name, info := range queues {
+		for _, worker := range this.workers {
+			if len(worker.Queues) == 0 || containsString(worker.Queues, name) {
+				info.Workers++
+			}
+		}
+		result = append(result, *info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Cancel 取消任务等待分发及正在 worker 上执行的所有实例，worker 在下次心跳时停止执行
+func (this *RemoteExecutor) Cancel(jobId string) {
+	this.mu.Lock()
+	var acquired []jobs.Job
+	queue := this.queue[:0]
+	for _, item := range this.queue {
+		if item.job.OriginalId() != jobId {
+			queue = append(queue, item)
+		} else if item.acquired {
+			acquired = append(acquired, item.job)
+		}
+	}
+	this.queue = queue
+	for _, l := range this.leases {
+		if l.Job.OriginalId() == jobId {
+			l.cancelled = true
+		}
+	}
+	this.broadcast()
+	this.mu.Unlock()
+
+	this.dropPending(jobId)
+	for _, job := range acquired {
+		this.releaseInstance(job)
+	}
+}
+
+// Shutdown 停止分发，等待分发的执行不再执行，worker 上正在执行的租约不再接收结果
+func (this *RemoteExecutor) Shutdown() {
+	this.mu.Lock()
+	this.cancel()
+	leased := len(this.leases)
+	queued := len(this.queue)
+	this.queue = nil
+	this.broadcast()
+	this.mu.Unlock()
+
+	this.dropPending("")
+	this.reaper.Wait()
+	if leased > 0 || queued > 0 {
+		log.Println("Executor shut down with", queued, "queued runs not dispatched and", leased, "runs leased to workers")
+	}
+}
+
+func newRemoteExecutor() Executor {
+	executor := &RemoteExecutor{
+		instances: newInstances(),
+		changed:   make(chan struct{}),
+		workers:   make(map[string]*WorkerInfo),
+		leases:    make(map[string]*lease),
+		leased:    make(map[string]int),
+	}
+	executor.ctx, executor.cancel = context.WithCancel(context.Background())
+	return executor
+}
@@ -0,0 +1,371 @@
+package executors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	// test.output 写出输出，等待 ms 毫秒后返回
+	MustRegister("test.output", func(ctx context.Context, ms int) error {
+		_, _ = fmt.Fprintln(RunOutput(ctx), "started")
+		select {
+		case <-time.After(time.Duration(ms) * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		_, _ = fmt.Fprintln(RunOutput(ctx), "finished")
+		return nil
+	})
+}
+
+// remoteServer 以与 api 相同的协议提供 worker 的接口，可替换 executor 以模拟调度器重启
+type remoteServer struct {
+	mu       sync.Mutex
+	executor *RemoteExecutor
+}
+
+func (this *remoteServer) remote() *RemoteExecutor {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.executor
+}
+
+func (this *remoteServer) replace(executor *RemoteExecutor) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.executor = executor
+}
+
+func (this *remoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	remote := this.remote()
+	var data interface{}
+	var err error
+	switch r.URL.Path {
+	case "/api/worker/register":
+		var registration WorkerRegistration
+		if err = json.NewDecoder(r.Body).Decode(&registration); err == nil {
+			data = remote.Register(registration)
+		}
+	case "/api/worker/poll":
+		var poll PollRequest
+		if err = json.NewDecoder(r.Body).Decode(&poll); err == nil {
+			data, err = remote.Poll(r.Context(), poll.WorkerId, time.Duration(poll.Wait*float64(time.Second)))
+		}
+	case "/api/worker/heartbeat":
+		var heartbeat Heartbeat
+		if err = json.NewDecoder(r.Body).Decode(&heartbeat); err == nil {
+			data, err = remote.Heartbeat(heartbeat)
+		}
+	case "/api/worker/result":
+		var result LeaseResult
+		if err = json.NewDecoder(r.Body).Decode(&result); err == nil {
+			err = remote.Complete(result)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	resp := workerResponse{Message: "success"}
+	if err != nil {
+		resp.Code, resp.Message = 1, err.Error()
+	}
+	resp.Data, _ = json.Marshal(data)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func newTestRemoteExecutor(t *testing.T, option ExecutorOption) *RemoteExecutor {
+	executor := newRemoteExecutor().(*RemoteExecutor)
+	executor.setOption(option)
+	t.Cleanup(executor.Shutdown)
+	return executor
+}
+
+// startWorker 启动连接到 server 的 worker，测试结束时停止
+func startWorker(t *testing.T, server *remoteServer, name string) {
+	httpServer := httptest.NewServer(server)
+	worker := NewWorker(WorkerOption{SchedulerURL: httpServer.URL, Name: name, PoolSize: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		_ = worker.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+		httpServer.Close()
+	})
+}
+
+// waitFor 等待 condition 成立
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func hasEvent(jobId, eventType, message string) bool {
+	for _, e := range Events(jobId) {
+		if e.Type == eventType && strings.Contains(e.Message, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRemoteExecutorRoundTrip(t *testing.T) {
+	ch := watch(t, "remote/roundtrip", 1)
+	executor := newTestRemoteExecutor(t, ExecutorOption{QueueSize: 1, LeaseTimeout: 300 * time.Millisecond})
+	startWorker(t, &remoteServer{executor: executor}, "w1")
+
+	// 执行时间超过租约时间，由心跳续租
+	if err := executor.Add(jobs.Job{Id: "remote/roundtrip", FuncName: "test.output", Args: []interface{}{700}}); err != nil {
+		t.Fatal(err)
+	}
+	result := results(t, ch, 1)[0]
+	if result.Status != jobs.RunSucceeded || result.Err != nil {
+		t.Fatalf("%s: %v", result.Status, result.Err)
+	}
+	if output := result.Output.String(); output != "started\nfinished\n" {
+		t.Fatalf("output %q", output)
+	}
+	if hasEvent("remote/roundtrip", EventLeaseExpired, "") {
+		t.Fatalf("lease expired: %v", Events("remote/roundtrip"))
+	}
+	status := executor.Status()
+	if len(status.Workers) != 1 || status.Workers[0].Name != "w1" || len(status.Leases) != 0 || status.Queued != 0 {
+		t.Fatalf("status %+v", status)
+	}
+}
+
+func TestRemoteExecutorLeaseExpiry(t *testing.T) {
+	ch := watch(t, "remote/expire", 1)
+	executor := newTestRemoteExecutor(t, ExecutorOption{LeaseTimeout: time.Hour})
+	workerId := executor.Register(WorkerRegistration{Name: "w", Kinds: []string{jobs.KindFunc}, Funcs: []string{"test.count"}}).WorkerId
+	if err := executor.Add(jobs.Job{Id: "remote/expire", FuncName: "test.count"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var expired []string
+	for dispatch := 1; dispatch <= MaxLeaseDispatches; dispatch++ {
+		l, err := executor.Poll(context.Background(), workerId, 0)
+		if err != nil || l == nil {
+			t.Fatalf("dispatch %d: lease %v, err %v", dispatch, l, err)
+		}
+		if leases := executor.Status().Leases; len(leases) != 1 || leases[0].Dispatches != dispatch {
+			t.Fatalf("dispatch %d: leases %+v", dispatch, leases)
+		}
+		// worker 未续租，租约过期
+		executor.expire(time.Now().Add(time.Hour + time.Second))
+		expired = append(expired, l.Id)
+	}
+
+	// 达到最大分发次数后视为执行失败，不再分发
+	result := results(t, ch, 1)[0]
+	if result.Status != jobs.RunFailed || !strings.Contains(result.Err.Error(), fmt.Sprintf("dispatched %d times", MaxLeaseDispatches)) {
+		t.Fatalf("%s: %v", result.Status, result.Err)
+	}
+	if !hasEvent("remote/expire", EventLeaseExpired, "redispatched") || !hasEvent("remote/expire", EventLeaseExpired, "giving up") {
+		t.Fatalf("events %v", Events("remote/expire"))
+	}
+	if l, err := executor.Poll(context.Background(), workerId, 0); l != nil || err != nil {
+		t.Fatalf("redispatched after giving up: lease %v, err %v", l, err)
+	}
+
+	// 过期的租约不再接收结果，续租时通知 worker 停止执行
+	if err := executor.Complete(LeaseResult{WorkerId: workerId, LeaseId: expired[0], Status: jobs.RunSucceeded}); err != ErrLeaseNotFound {
+		t.Fatalf("Complete returned %v", err)
+	}
+	reply, err := executor.Heartbeat(Heartbeat{WorkerId: workerId, Leases: expired})
+	if err != nil || len(reply.Cancel) != len(expired) {
+		t.Fatalf("reply %+v, err %v", reply, err)
+	}
+}
+
+func TestRemoteExecutorCancelThroughHeartbeat(t *testing.T) {
+	ch := watch(t, "remote/cancel", 1)
+	executor := newTestRemoteExecutor(t, ExecutorOption{LeaseTimeout: 300 * time.Millisecond})
+	startWorker(t, &remoteServer{executor: executor}, "w1")
+
+	if err := executor.Add(jobs.Job{Id: "remote/cancel", FuncName: "test.block"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the job to be leased", func() bool { return len(executor.Status().Leases) == 1 })
+	executor.Cancel("remote/cancel")
+	if leases := executor.Status().Leases; len(leases) != 1 || !leases[0].Cancelled {
+		t.Fatalf("leases %+v", leases)
+	}
+
+	// worker 在下次心跳时收到取消，停止执行并上报结果
+	result := results(t, ch, 1)[0]
+	if result.Status == jobs.RunSucceeded {
+		t.Fatalf("%s: %v", result.Status, result.Err)
+	}
+	if leases := executor.Status().Leases; len(leases) != 0 {
+		t.Fatalf("leases %+v", leases)
+	}
+}
+
+func TestRemoteExecutorUnknownWorker(t *testing.T) {
+	ch := watch(t, "remote/reregister", 1)
+	first := newTestRemoteExecutor(t, ExecutorOption{LeaseTimeout: time.Second})
+	server := &remoteServer{executor: first}
+	startWorker(t, server, "w1")
+	waitFor(t, "the worker to register", func() bool { return len(first.Status().Workers) == 1 })
+	workerId := first.Status().Workers[0].WorkerId
+
+	// 模拟调度器重启: 新的 executor 不认识该 worker，worker 以原 id 重新注册后继续领取
+	second := newTestRemoteExecutor(t, ExecutorOption{LeaseTimeout: time.Second})
+	if _, err := second.Poll(context.Background(), workerId, 0); err != ErrUnknownWorker {
+		t.Fatalf("Poll returned %v", err)
+	}
+	if _, err := second.Heartbeat(Heartbeat{WorkerId: workerId}); err != ErrUnknownWorker {
+		t.Fatalf("Heartbeat returned %v", err)
+	}
+	server.replace(second)
+	first.Shutdown()
+	if err := second.Add(jobs.Job{Id: "remote/reregister", FuncName: "test.count"}); err != nil {
+		t.Fatal(err)
+	}
+	result := results(t, ch, 1)[0]
+	if result.Status != jobs.RunSucceeded {
+		t.Fatalf("%s: %v", result.Status, result.Err)
+	}
+	if workers := second.Status().Workers; len(workers) != 1 || workers[0].WorkerId != workerId {
+		t.Fatalf("workers %+v", workers)
+	}
+}
+
+func TestWorkerSupports(t *testing.T) {
+	worker := &WorkerInfo{WorkerRegistration: WorkerRegistration{
+		Kinds:  []string{jobs.KindFunc, jobs.KindHTTP},
+		Funcs:  []string{"test.count"},
+		Labels: map[string]string{"region": "eu", "gpu": "true"},
+		Queues: []string{"reports"},
+	}}
+	anyQueue := &WorkerInfo{WorkerRegistration: WorkerRegistration{Kinds: []string{jobs.KindFunc}, Funcs: []string{"test.count"}}}
+	tests := []struct {
+		name     string
+		worker   *WorkerInfo
+		job      jobs.Job
+		supports bool
+	}{
+		{"matching queue", worker, jobs.Job{FuncName: "test.count", Queue: "reports"}, true},
+		{"other queue", worker, jobs.Job{FuncName: "test.count", Queue: "billing"}, false},
+		{"default queue", worker, jobs.Job{FuncName: "test.count"}, false},
+		{"any queue", anyQueue, jobs.Job{FuncName: "test.count", Queue: "billing"}, true},
+		{"matching labels", worker, jobs.Job{FuncName: "test.count", Queue: "reports", Labels: map[string]string{"region": "eu"}}, true},
+		{"mismatched label", worker, jobs.Job{FuncName: "test.count", Queue: "reports", Labels: map[string]string{"region": "us"}}, false},
+		{"missing label", worker, jobs.Job{FuncName: "test.count", Queue: "reports", Labels: map[string]string{"zone": "a"}}, false},
+		{"unlabelled worker", anyQueue, jobs.Job{FuncName: "test.count", Labels: map[string]string{"gpu": "true"}}, false},
+		{"unregistered func", worker, jobs.Job{FuncName: "test.wait", Queue: "reports"}, false},
+		{"supported kind", worker, jobs.Job{Kind: jobs.KindHTTP, Queue: "reports"}, true},
+		{"unsupported kind", worker, jobs.Job{Kind: jobs.KindShell, Queue: "reports"}, false},
+	}
+	for _, test := range tests {
+		if got := test.worker.supports(test.job); got != test.supports {
+			t.Fatalf("%s: supports %v, want %v", test.name, got, test.supports)
+		}
+	}
+}
+
+// queueCounts 返回各队列等待分发及正在执行的任务数
+func queueCounts(executor *RemoteExecutor) map[string][2]int {
+	counts := make(map[string][2]int)
+	for _, queue := range executor.Queues() {
+		counts[queue.Name] = [2]int{queue.Queued, queue.Leased}
+	}
+	return counts
+}
+
+func TestRemoteExecutorTake(t *testing.T) {
+	executor := newTestRemoteExecutor(t, ExecutorOption{LeaseTimeout: time.Hour, QueueLimits: map[string]int{"reports": 1}})
+	register := func(registration WorkerRegistration) string {
+		registration.Kinds = []string{jobs.KindFunc}
+		registration.Funcs = []string{"test.count"}
+		return executor.Register(registration).WorkerId
+	}
+	plain := register(WorkerRegistration{Name: "plain"})
+	gpu := register(WorkerRegistration{Name: "gpu", Labels: map[string]string{"gpu": "true"}})
+	billing := register(WorkerRegistration{Name: "billing", Queues: []string{"billing"}})
+	for _, job := range []jobs.Job{
+		{Id: "take/report-1", FuncName: "test.count", Queue: "reports"},
+		{Id: "take/report-2", FuncName: "test.count", Queue: "reports"},
+		{Id: "take/gpu", FuncName: "test.count", Labels: map[string]string{"gpu": "true"}},
+		{Id: "take/default", FuncName: "test.count"},
+	} {
+		if err := executor.Add(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	leases := make(map[string]*Lease)
+	tests := []struct {
+		name     string
+		workerId string
+		want     string
+		counts   map[string][2]int
+	}{
+		{"first in queue", plain, "take/report-1",
+			map[string][2]int{"reports": {1, 1}, jobs.DefaultQueue: {2, 0}, "billing": {0, 0}}},
+		// reports 队列达到并发上限，不影响其他队列的分发；缺少标签的任务跳过
+		{"full queue skipped", plain, "take/default",
+			map[string][2]int{"reports": {1, 1}, jobs.DefaultQueue: {1, 1}, "billing": {0, 0}}},
+		{"nothing for plain", plain, "", nil},
+		// 队列不匹配的 worker 不领取
+		{"other queue", billing, "", nil},
+		{"matching labels", gpu, "take/gpu",
+			map[string][2]int{"reports": {1, 1}, jobs.DefaultQueue: {0, 2}, "billing": {0, 0}}},
+	}
+	for _, test := range tests {
+		l, err := executor.Poll(context.Background(), test.workerId, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got := ""
+		if l != nil {
+			got = l.Job.Id
+			leases[got] = l
+		}
+		if got != test.want {
+			t.Fatalf("%s: leased %q, want %q", test.name, got, test.want)
+		}
+		if counts := queueCounts(executor); test.counts != nil && fmt.Sprint(counts) != fmt.Sprint(test.counts) {
+			t.Fatalf("%s: queues %v, want %v", test.name, counts, test.counts)
+		}
+	}
+
+	// 执行结束后释放队列的并发数，等待中的任务可以分发
+	if err := executor.Complete(LeaseResult{WorkerId: plain, LeaseId: leases["take/report-1"].Id, Status: jobs.RunSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+	if counts := queueCounts(executor); counts["reports"] != [2]int{1, 0} {
+		t.Fatalf("queues %v after complete", counts)
+	}
+	l, err := executor.Poll(context.Background(), plain, 0)
+	if err != nil || l == nil || l.Job.Id != "take/report-2" {
+		t.Fatalf("lease %v, err %v", l, err)
+	}
+
+	// 租约过期后重新放回各自的队列
+	executor.expire(time.Now().Add(time.Hour + time.Second))
+	if counts := queueCounts(executor); counts["reports"] != [2]int{1, 0} || counts[jobs.DefaultQueue] != [2]int{2, 0} {
+		t.Fatalf("queues %v after expire", counts)
+	}
+	if status := executor.Status(); len(status.Leases) != 0 || status.Queued != 3 {
+		t.Fatalf("status %+v after expire", status)
+	}
+}
@@ -0,0 +1,81 @@
+package executors
+
+import (
+	"encoding/json"
+	"go-Job-Scheduler/jobs"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Result 任务一次执行的结果，Status 见 jobs.RunSucceeded 等
+type Result struct {
+	RunId     string
+	Job       jobs.Job
+	StartTime time.Time
+	EndTime   time.Time
+	Values    []reflect.Value
+	Status    string
+	Err       error
+	// Output 本次执行的输出
+	Output *RunLog
+}
+
+// Record 将执行结果转换为执行记录
+func (result *Result) Record() jobs.RunRecord {
+	record := jobs.RunRecord{
+		Id:            result.RunId,
+		JobId:         result.Job.OriginalId(),
+		JobName:       result.Job.Name,
+		ScheduledTime: result.Job.NextRunTime_,
+		StartTime:     result.StartTime,
+		EndTime:       result.EndTime,
+		Status:        result.Status,
+		Attempt:       result.Job.CurrentAttempt(),
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	if result.Output != nil {
+		record.Output = result.Output.String()
+		record.OutputTruncated = result.Output.Truncated()
+	}
+	if len(result.Values) > 0 {
+		values := make([]interface{}, len(result.Values))
+		for i, v := range result.Values {
+			values[i] = valueInterface(v)
+		}
+		if b, err := json.Marshal(values); err == nil {
+			record.Result = b
+		}
+	}
+	return record
+}
+
+// valueInterface 返回值对应的 interface{}，无效的 reflect.Value 视为 nil
+func valueInterface(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+var (
+	listenersMu sync.RWMutex
+	listeners   []func(Result)
+)
+
+// AddResultListener 注册执行结果监听函数，每次任务执行结束后调用
+func AddResultListener(listener func(Result)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, listener)
+}
+
+func notify(result Result) {
+	listenersMu.RLock()
+	defer listenersMu.RUnlock()
+	for _, listener := range listeners {
+		listener(result)
+	}
+}
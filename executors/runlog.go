@@ -0,0 +1,157 @@
+package executors
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// DefaultRunLogSize 每次执行保存的最大输出字节数，超出部分丢弃
+const DefaultRunLogSize = 64 * 1024
+
+// truncatedMark 输出被截断时追加的标记
+const truncatedMark = "\n... output truncated\n"
+
+// RunLog 一次执行的输出，超过大小上限后截断，可在执行过程中被持续读取
+type RunLog struct {
+	JobId     string
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+	closed    bool
+	// changed 每次写入或结束时关闭并替换，用于通知读取者
+	changed chan struct{}
+}
+
+func newRunLog(jobId string, limit int) *RunLog {
+	if limit <= 0 {
+		limit = DefaultRunLogSize
+	}
+	return &RunLog{JobId: jobId, limit: limit, changed: make(chan struct{})}
+}
+
+// Write 写入输出，超出大小上限的部分被丢弃，执行结束后的写入被忽略
+func (runLog *RunLog) Write(p []byte) (int, error) {
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+
+	if runLog.closed || runLog.truncated {
+		return len(p), nil
+	}
+	if remaining := runLog.limit - runLog.buf.Len(); len(p) > remaining {
+		runLog.buf.Write(p[:remaining])
+		runLog.buf.WriteString(truncatedMark)
+		runLog.truncated = true
+	} else {
+		runLog.buf.Write(p)
+	}
+	runLog.signal()
+	return len(p), nil
+}
+
+func (runLog *RunLog) signal() {
+	close(runLog.changed)
+	runLog.changed = make(chan struct{})
+}
+
+// close 标记执行结束，通知所有读取者
+func (runLog *RunLog) close() {
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+
+	if runLog.closed {
+		return
+	}
+	runLog.closed = true
+	runLog.signal()
+}
+
+// Read 返回从 offset 起的输出，changed 在有新输出或执行结束时关闭，done 表示执行已结束
+func (runLog *RunLog) Read(offset int) (data []byte, changed <-chan struct{}, done bool) {
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+
+	if offset < runLog.buf.Len() {
+		data = append([]byte(nil), runLog.buf.Bytes()[offset:]...)
+	}
+	return data, runLog.changed, runLog.closed
+}
+
+// String 返回目前为止的全部输出
+func (runLog *RunLog) String() string {
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+	return runLog.buf.String()
+}
+
+// markTruncated 标记输出已被截断，如远程 worker 上的输出
+func (runLog *RunLog) markTruncated() {
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+	runLog.truncated = true
+}
+
+// Truncated 输出是否因超过大小上限被截断
+func (runLog *RunLog) Truncated() bool {
+	runLog.mu.Lock()
+	defer runLog.mu.Unlock()
+	return runLog.truncated
+}
+
+var (
+	liveLogsMu sync.RWMutex
+	// liveLogs 正在执行的任务的输出，按执行 id 索引
+	liveLogs = make(map[string]*RunLog)
+)
+
+// LiveRunLog 返回正在执行的任务的输出，执行已结束时返回 false
+func LiveRunLog(runId string) (*RunLog, bool) {
+	liveLogsMu.RLock()
+	defer liveLogsMu.RUnlock()
+	runLog, ok := liveLogs[runId]
+	return runLog, ok
+}
+
+// LiveRunIds 返回任务正在执行的所有执行 id
+func LiveRunIds(jobId string) []string {
+	liveLogsMu.RLock()
+	defer liveLogsMu.RUnlock()
+	var ids []string
+	for runId, runLog := range liveLogs {
+		if runLog.JobId == jobId {
+			ids = append(ids, runId)
+		}
+	}
+	return ids
+}
+
+func registerRunLog(runId string, runLog *RunLog) {
+	liveLogsMu.Lock()
+	defer liveLogsMu.Unlock()
+	liveLogs[runId] = runLog
+}
+
+func unregisterRunLog(runId string) {
+	liveLogsMu.Lock()
+	defer liveLogsMu.Unlock()
+	delete(liveLogs, runId)
+}
+
+type runLogKey struct{}
+
+// RunOutput 返回本次执行的输出，由执行器通过 context 传给函数；不在执行中时返回标准输出
+func RunOutput(ctx context.Context) io.Writer {
+	if runLog, ok := ctx.Value(runLogKey{}).(*RunLog); ok {
+		return runLog
+	}
+	return os.Stdout
+}
+
+// Logger 返回写入本次执行输出的 logger，任务函数通过第一个参数 context.Context 获取
+func Logger(ctx context.Context) *log.Logger {
+	return log.New(RunOutput(ctx), "", log.LstdFlags|log.Lmicroseconds)
+}
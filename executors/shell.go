@@ -0,0 +1,135 @@
+package executors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	shellAllowMu sync.RWMutex
+	// shellAllowList 允许执行的命令，为空时禁止执行 shell 类型任务
+	shellAllowList = make(map[string]bool)
+	// shellEnvAllowList 任务可设置的环境变量名，shellDirAllowList 任务可使用的工作目录，为空时不允许设置
+	shellEnvAllowList = make(map[string]bool)
+	shellDirAllowList = make(map[string]bool)
+)
+
+// SetShellAllowList 设置允许执行的命令、任务可设置的环境变量名及可使用的工作目录，
+// 命令及环境变量名需与任务定义完全一致，工作目录按 filepath.Clean 后比较
+func SetShellAllowList(commands, envNames, dirs []string) {
+	shellAllowMu.Lock()
+	defer shellAllowMu.Unlock()
+	shellAllowList = stringSet(commands, nil)
+	shellEnvAllowList = stringSet(envNames, nil)
+	shellDirAllowList = stringSet(dirs, filepath.Clean)
+}
+
+// stringSet 将非空字符串转换为集合，clean 不为 nil 时先做转换
+func stringSet(values []string, clean func(string) string) map[string]bool {
+	set := make(map[string]bool)
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if clean != nil {
+			value = clean(value)
+		}
+		set[value] = true
+	}
+	return set
+}
+
+// checkShell 检查命令、环境变量名及工作目录是否在允许列表中，
+// 否则任务可通过 LD_PRELOAD、PATH 等环境变量或工作目录中的同名文件绕过命令的限制
+func checkShell(spec *jobs.ShellSpec) error {
+	shellAllowMu.RLock()
+	defer shellAllowMu.RUnlock()
+	if !shellAllowList[spec.Command] {
+		return errors.New(fmt.Sprintf("shell command %q is not allowed", spec.Command))
+	}
+	for name := range spec.Env {
+		if !shellEnvAllowList[name] {
+			return errors.New(fmt.Sprintf("shell env %q is not allowed", name))
+		}
+	}
+	if spec.Dir != "" && (!filepath.IsAbs(spec.Dir) || !shellDirAllowList[filepath.Clean(spec.Dir)]) {
+		return errors.New(fmt.Sprintf("shell dir %q is not allowed", spec.Dir))
+	}
+	return nil
+}
+
+// shellEnabled 是否允许执行 shell 类型任务
+func shellEnabled() bool {
+	shellAllowMu.RLock()
+	defer shellAllowMu.RUnlock()
+	return len(shellAllowList) > 0
+}
+
+// runShell 执行 shell 类型任务，输出写入本次执行的输出，返回值为退出码。
+// 超时或被取消时结束整个进程组
+func runShell(ctx context.Context, job jobs.Job) ([]reflect.Value, error) {
+	spec := job.Shell
+	if spec == nil {
+		return nil, errors.New("shell job requires a shell spec")
+	}
+	if err := checkShell(spec); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(spec.Command, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = shellEnv(spec.Env)
+	output := RunOutput(ctx)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-stopped:
+		}
+	}()
+	err := cmd.Wait()
+	close(stopped)
+
+	code := cmd.ProcessState.ExitCode()
+	values := []reflect.Value{reflect.ValueOf(code)}
+	if ctx.Err() != nil {
+		return values, ctx.Err()
+	}
+	if spec.Succeeded(code) {
+		return values, nil
+	}
+	if code < 0 {
+		return values, errors.New(fmt.Sprintf("shell command failed: %v", err))
+	}
+	return values, errors.New(fmt.Sprintf("shell command exited with code %d", code))
+}
+
+// shellEnv 返回调度器的环境变量加上任务定义的环境变量
+func shellEnv(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := os.Environ()
+	for _, name := range names {
+		result = append(result, name+"="+env[name])
+	}
+	return result
+}
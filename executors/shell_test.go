@@ -0,0 +1,37 @@
+package executors
+
+import (
+	"go-Job-Scheduler/jobs"
+	"testing"
+)
+
+func TestCheckShell(t *testing.T) {
+	SetShellAllowList([]string{"/usr/bin/rsync"}, []string{"RSYNC_RSH"}, []string{"/data/"})
+	defer SetShellAllowList(nil, nil, nil)
+
+	tests := []struct {
+		spec jobs.ShellSpec
+		ok   bool
+	}{
+		{jobs.ShellSpec{Command: "/usr/bin/rsync"}, true},
+		{jobs.ShellSpec{Command: "/bin/sh"}, false},
+		{jobs.ShellSpec{Command: "/usr/bin/rsync", Env: map[string]string{"RSYNC_RSH": "ssh -p 2222"}}, true},
+		{jobs.ShellSpec{Command: "/usr/bin/rsync", Env: map[string]string{"LD_PRELOAD": "/tmp/evil.so"}}, false},
+		{jobs.ShellSpec{Command: "/usr/bin/rsync", Env: map[string]string{"PATH": "/tmp"}}, false},
+		{jobs.ShellSpec{Command: "/usr/bin/rsync", Dir: "/data"}, true},
+		{jobs.ShellSpec{Command: "/usr/bin/rsync", Dir: "/data/../tmp"}, false},
+		{jobs.ShellSpec{Command: "/usr/bin/rsync", Dir: "/tmp"}, false},
+		{jobs.ShellSpec{Command: "/usr/bin/rsync", Dir: "data"}, false},
+	}
+	for _, test := range tests {
+		spec := test.spec
+		if err := checkShell(&spec); (err == nil) != test.ok {
+			t.Fatalf("%+v: err %v", test.spec, err)
+		}
+	}
+
+	SetShellAllowList([]string{"/usr/bin/rsync"}, nil, nil)
+	if err := checkShell(&jobs.ShellSpec{Command: "/usr/bin/rsync", Env: map[string]string{"RSYNC_RSH": "ssh"}}); err == nil {
+		t.Fatal("env allowed without --shell-env-allow")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package executors
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 命令在新的进程组中运行，以便结束时连同其子进程一起结束
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup 结束命令所在的整个进程组
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package executors
+
+import "os/exec"
+
+// setProcessGroup Windows 下不创建新的进程组
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup Windows 下只结束命令本身的进程
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,37 @@
+package executors
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"io/ioutil"
+)
+
+// tlsConfig 按任务的 TLS 选项创建客户端 TLS 配置
+func tlsConfig(spec *jobs.TLSSpec) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         spec.ServerName,
+		InsecureSkipVerify: spec.InsecureSkipVerify,
+	}
+	if spec.CAFile != "" {
+		pem, err := ioutil.ReadFile(spec.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New(fmt.Sprintf("no certificates found in %s", spec.CAFile))
+		}
+		config.RootCAs = pool
+	}
+	if spec.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(spec.CertFile, spec.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
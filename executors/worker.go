@@ -0,0 +1,352 @@
+package executors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWorkerPollWait worker 长轮询的等待时间
+	DefaultWorkerPollWait = 20 * time.Second
+	// workerRetryDelay 请求调度器失败后重试的间隔
+	workerRetryDelay = 2 * time.Second
+	// workerResultAttempts 上报执行结果的最大尝试次数
+	workerResultAttempts = 3
+)
+
+// WorkerOption worker 进程的配置
+type WorkerOption struct {
+	// SchedulerURL 调度器 web server 的地址，如 http://127.0.0.1:10028
+	SchedulerURL string
+	Name         string
+	// PoolSize 同时执行的任务数
+	PoolSize   int
+	RunLogSize int
+	// ShellAllowList 允许 shell 类型任务执行的命令，为空时不领取 shell 类型任务；
+	// ShellEnvAllowList 可设置的环境变量名，ShellDirAllowList 可使用的工作目录
+	ShellAllowList    []string
+	ShellEnvAllowList []string
+	ShellDirAllowList []string
+	// Labels worker 的标签，Queues 领取任务的队列，为空时领取所有队列的任务
+	Labels map[string]string
+	Queues []string
+}
+
+// Worker 远程执行器的 worker，从调度器领取可执行的任务，执行期间续租并上报输出，结束后上报结果
+type Worker struct {
+	option WorkerOption
+	client *http.Client
+	mu     sync.Mutex
+	id     string
+	// heartbeatInterval 续租间隔，为调度器租约时间的三分之一
+	heartbeatInterval time.Duration
+	// leases 正在执行的租约
+	leases map[string]*workerLease
+}
+
+// workerLease worker 上正在执行的租约
+type workerLease struct {
+	cancel context.CancelFunc
+	runLog *RunLog
+	// sent 已上报的输出字节数
+	sent int
+}
+
+func NewWorker(option WorkerOption) *Worker {
+	if option.PoolSize <= 0 {
+		option.PoolSize = DefaultMaxPoolSize
+	}
+	if option.RunLogSize <= 0 {
+		option.RunLogSize = DefaultRunLogSize
+	}
+	option.SchedulerURL = strings.TrimRight(option.SchedulerURL, "/")
+	return &Worker{
+		option: option,
+		client: &http.Client{},
+		leases: make(map[string]*workerLease),
+	}
+}
+
+// Run 注册并开始领取执行，ctx 取消后停止领取，取消正在执行的任务并上报结果后返回
+func (this *Worker) Run(ctx context.Context) error {
+	SetShellAllowList(this.option.ShellAllowList, this.option.ShellEnvAllowList, this.option.ShellDirAllowList)
+	for {
+		err := this.register()
+		if err == nil {
+			break
+		}
+		log.Println("Error: register worker,", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(workerRetryDelay):
+		}
+	}
+
+	// 心跳在所有执行上报结果后才停止，因此不使用 ctx
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	defer stopHeartbeat()
+	go this.heartbeat(heartbeatCtx)
+
+	var pollers sync.WaitGroup
+	for i := 0; i < this.option.PoolSize; i++ {
+		pollers.Add(1)
+		go func() {
+			defer pollers.Done()
+			this.poll(ctx)
+		}()
+	}
+	<-ctx.Done()
+	// 正在执行的任务随 ctx 取消，等待其上报结果
+	log.Println("Worker shutting down, cancelling running jobs")
+	pollers.Wait()
+	return nil
+}
+
+// register 向调度器注册 worker 可执行的函数及任务类型，重新注册时沿用已分配的 id
+func (this *Worker) register() error {
+	this.mu.Lock()
+	registration := WorkerRegistration{
+		WorkerId: this.id,
+		Name:     this.option.Name,
+		Funcs:    registeredFuncNames(),
+		Kinds:    supportedKinds(),
+		Labels:   this.option.Labels,
+		Queues:   this.option.Queues,
+	}
+	this.mu.Unlock()
+
+	var registered WorkerRegistered
+	if err := this.post(context.Background(), "/api/worker/register", registration, &registered); err != nil {
+		return err
+	}
+	this.mu.Lock()
+	this.id = registered.WorkerId
+	this.heartbeatInterval = time.Duration(registered.LeaseTimeout * float64(time.Second) / 3)
+	this.mu.Unlock()
+	log.Println("Worker registered as", registered.WorkerId, ", kinds", registration.Kinds, "funcs", registration.Funcs)
+	return nil
+}
+
+// supportedKinds 返回可执行的任务类型，未设置 shell 命令允许列表时不包括 shell
+func supportedKinds() []string {
+	kinds := make([]string, 0, len(kindRunners))
+	for kind := range kindRunners {
+		if kind == jobs.KindShell && !shellEnabled() {
+			continue
+		}
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (this *Worker) workerId() string {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.id
+}
+
+// poll 长轮询领取执行并执行，直到 ctx 取消
+func (this *Worker) poll(ctx context.Context) {
+	for ctx.Err() == nil {
+		var lease *Lease
+		request := PollRequest{WorkerId: this.workerId(), Wait: DefaultWorkerPollWait.Seconds()}
+		err := this.post(ctx, "/api/worker/poll", request, &lease)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Println("Error: poll,", err)
+			this.reregister(err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(workerRetryDelay):
+			}
+			continue
+		}
+		if lease != nil {
+			this.execute(ctx, *lease)
+		}
+	}
+}
+
+// reregister 调度器重启后不再认识 worker 时重新注册
+func (this *Worker) reregister(err error) {
+	if err.Error() != ErrUnknownWorker.Error() {
+		return
+	}
+	if err = this.register(); err != nil {
+		log.Println("Error: register worker,", err)
+	}
+}
+
+// execute 在任务的超时时间内执行租约中的任务并上报结果，ctx 取消时取消执行。
+// 超时后函数仍未返回时等待其返回，worker 停止时不再等待
+func (this *Worker) execute(ctx context.Context, lease Lease) {
+	job := lease.Job
+	log.Println("Executing job", job.Id, ", lease", lease.Id)
+	stop := ctx.Done()
+	var cancel context.CancelFunc
+	if job.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	runLog := newRunLog(job.OriginalId(), this.option.RunLogSize)
+	active := &workerLease{cancel: cancel, runLog: runLog}
+	this.mu.Lock()
+	this.leases[lease.Id] = active
+	this.mu.Unlock()
+
+	start := time.Now()
+	values, status, returned, err := invoke(context.WithValue(ctx, runLogKey{}, runLog), job)
+	runLog.close()
+	log.Println("Executing job", job.Id, ". Done", status)
+
+	this.mu.Lock()
+	delete(this.leases, lease.Id)
+	output, _, _ := runLog.Read(active.sent)
+	this.mu.Unlock()
+
+	result := LeaseResult{
+		WorkerId:        this.workerId(),
+		LeaseId:         lease.Id,
+		StartTime:       start,
+		EndTime:         time.Now(),
+		Status:          status,
+		Output:          string(output),
+		OutputTruncated: runLog.Truncated(),
+	}
+	if err != nil {
+		log.Println("Error:", job.Id, err)
+		result.Error = err.Error()
+		var decided *jobs.RetryableError
+		if errors.As(err, &decided) {
+			result.Retryable = &decided.Retry
+		}
+	}
+	for _, v := range values {
+		value := valueInterface(v)
+		// 无法编码为 JSON 的返回值以字符串上报
+		if _, err := json.Marshal(value); err != nil {
+			value = fmt.Sprint(value)
+		}
+		result.Values = append(result.Values, value)
+	}
+	this.report(result)
+	// 函数返回后才领取下一个任务，worker 同时执行的任务数不超过 PoolSize
+	waitReturned(job, returned, stop)
+}
+
+// report 上报执行结果，失败时重试，租约已过期时丢弃
+func (this *Worker) report(result LeaseResult) {
+	for attempt := 1; ; attempt++ {
+		err := this.post(context.Background(), "/api/worker/result", result, nil)
+		if err == nil {
+			return
+		}
+		if err.Error() == ErrLeaseNotFound.Error() {
+			log.Println("Lease", result.LeaseId, "expired, result discarded")
+			return
+		}
+		if attempt >= workerResultAttempts {
+			log.Println("Error: report result of lease", result.LeaseId, ",", err)
+			return
+		}
+		time.Sleep(workerRetryDelay)
+	}
+}
+
+// heartbeat 定期为正在执行的租约续租并上报新的输出，停止被取消或已过期的租约
+func (this *Worker) heartbeat(ctx context.Context) {
+	for {
+		this.mu.Lock()
+		interval := this.heartbeatInterval
+		this.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		heartbeat := Heartbeat{WorkerId: this.workerId(), Output: make(map[string]string)}
+		this.mu.Lock()
+		for id, l := range this.leases {
+			heartbeat.Leases = append(heartbeat.Leases, id)
+			// 上报失败时这部分输出不再重发
+			if output, _, _ := l.runLog.Read(l.sent); len(output) > 0 {
+				heartbeat.Output[id] = string(output)
+				l.sent += len(output)
+			}
+		}
+		this.mu.Unlock()
+
+		var reply HeartbeatReply
+		if err := this.post(ctx, "/api/worker/heartbeat", heartbeat, &reply); err != nil {
+			if ctx.Err() == nil {
+				log.Println("Error: heartbeat,", err)
+				this.reregister(err)
+			}
+			continue
+		}
+		this.mu.Lock()
+		for _, id := range reply.Cancel {
+			if l, ok := this.leases[id]; ok {
+				log.Println("Lease", id, "cancelled by scheduler")
+				l.cancel()
+			}
+		}
+		this.mu.Unlock()
+	}
+}
+
+// workerResponse 调度器 api 的响应
+type workerResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// post 请求调度器 api，api 返回错误时以其 message 作为错误信息，data 解码到 result
+func (this *Worker) post(ctx context.Context, path string, body interface{}, result interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, this.option.SchedulerURL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("%s: %s", path, resp.Status))
+	}
+	var r workerResponse
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return err
+	}
+	if r.Code != 0 {
+		return errors.New(r.Message)
+	}
+	if result == nil || len(r.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Data, result)
+}
@@ -3,6 +3,16 @@ module go-Job-Scheduler
 go 1.17
 
 require (
-	github.com/go-redis/redis v6.15.9+incompatible // indirect
-	github.com/google/uuid v1.3.0 // indirect
+	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/google/uuid v1.3.0
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.30.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/net v0.11.0 // indirect
+	golang.org/x/sys v0.9.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
 )
@@ -0,0 +1,133 @@
+package histories
+
+import (
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/jobs"
+	"strconv"
+	"time"
+)
+
+// 执行记录的默认保留策略
+const (
+	// DefaultMaxRuns 每个任务保留的最大执行记录数
+	DefaultMaxRuns = 100
+	// DefaultMaxAge 执行记录的最长保留时间
+	DefaultMaxAge = 7 * 24 * time.Hour
+)
+
+// History 任务执行记录的存储
+type History interface {
+	setOption(HistoryOption) error
+	// Add 保存一条执行记录，并按保留策略删除过期的记录
+	Add(jobs.RunRecord) error
+	Get(string) *jobs.RunRecord
+	// Query 按开始时间倒序返回符合条件的执行记录
+	Query(Filter) []jobs.RunRecord
+}
+
+type HistoryOption struct {
+	Host     string
+	Port     string
+	DBName   string
+	Password string
+	// MaxRuns 每个任务保留的最大记录数，MaxAge 记录的最长保留时间，为 0 时不限
+	MaxRuns int
+	MaxAge  time.Duration
+}
+
+// Filter 执行记录的查询条件，为空的条件不过滤
+type Filter struct {
+	JobId  string
+	Status string
+	// From、To 按开始时间过滤 [From, To]
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// Match 判断执行记录是否符合查询条件
+func (filter *Filter) Match(record *jobs.RunRecord) bool {
+	if filter.JobId != "" && record.JobId != filter.JobId {
+		return false
+	}
+	if filter.Status != "" && record.Status != filter.Status {
+		return false
+	}
+	if !filter.From.IsZero() && record.StartTime.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && record.StartTime.After(filter.To) {
+		return false
+	}
+	return true
+}
+
+var histories = make(map[string]History)
+
+func init() {
+	registerHistories()
+}
+
+func MapToHistoryOption(m map[string]interface{}) HistoryOption {
+	var options HistoryOption
+
+	if v, ok := m["host"].(string); ok {
+		options.Host = v
+	} else {
+		options.Host = "127.0.0.1"
+	}
+
+	if option, exist := m["port"]; exist {
+		if v, ok := option.(string); ok {
+			options.Port = v
+		} else if i, ok := option.(int); ok {
+			options.Port = strconv.Itoa(i)
+		} else {
+			options.Port = "0"
+		}
+	}
+
+	if option, exist := m["dbname"]; exist {
+		if v, ok := option.(string); ok {
+			options.DBName = v
+		} else if i, ok := option.(int); ok {
+			options.DBName = strconv.Itoa(i)
+		}
+	}
+
+	if v, ok := m["password"].(string); ok {
+		options.Password = v
+	}
+
+	if v, ok := m["maxRuns"].(int); ok {
+		options.MaxRuns = v
+	} else {
+		options.MaxRuns = DefaultMaxRuns
+	}
+
+	if v, ok := m["maxAge"].(time.Duration); ok {
+		options.MaxAge = v
+	} else {
+		options.MaxAge = DefaultMaxAge
+	}
+	return options
+}
+
+func registerHistories() {
+	// 注册各种执行记录存储
+	histories["redis"] = newRedisHistory()
+	histories["memory"] = newMemoryHistory()
+}
+
+// NewHistory 按类型创建执行记录存储，类型不存在或无法连接时返回错误
+func NewHistory(typeStr string, option HistoryOption) (History, error) {
+	v, ok := histories[typeStr]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown history type %s", typeStr))
+	}
+	if err := v.setOption(option); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,109 @@
+package histories
+
+import (
+	"go-Job-Scheduler/jobs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryHistory 保存在内存中的执行记录，重启后丢失
+type MemoryHistory struct {
+	maxRuns int
+	maxAge  time.Duration
+	// runs 每个任务的执行记录，按开始时间升序
+	runs map[string][]jobs.RunRecord
+	sync.RWMutex
+}
+
+func newMemoryHistory() History {
+	return &MemoryHistory{
+		runs: make(map[string][]jobs.RunRecord),
+	}
+}
+
+func (history *MemoryHistory) setOption(option HistoryOption) error {
+	history.maxRuns = option.MaxRuns
+	history.maxAge = option.MaxAge
+	return nil
+}
+
+func (history *MemoryHistory) Add(record jobs.RunRecord) error {
+	// 加锁
+	history.Lock()
+	// 函数执行完毕前解锁
+	defer history.Unlock()
+
+	runs := append(history.runs[record.JobId], record)
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartTime.Before(runs[j].StartTime)
+	})
+	history.runs[record.JobId] = runs
+	history.prune(record.JobId)
+	return nil
+}
+
+// prune 按保留策略删除任务过期的执行记录
+func (history *MemoryHistory) prune(jobId string) {
+	runs := history.runs[jobId]
+	if history.maxAge > 0 {
+		expired := time.Now().Add(-history.maxAge)
+		i := 0
+		for i < len(runs) && runs[i].StartTime.Before(expired) {
+			i++
+		}
+		runs = runs[i:]
+	}
+	if history.maxRuns > 0 && len(runs) > history.maxRuns {
+		runs = runs[len(runs)-history.maxRuns:]
+	}
+	if len(runs) == 0 {
+		delete(history.runs, jobId)
+		return
+	}
+	history.runs[jobId] = append([]jobs.RunRecord(nil), runs...)
+}
+
+func (history *MemoryHistory) Get(id string) *jobs.RunRecord {
+	history.RLock()
+	defer history.RUnlock()
+
+	for _, runs := range history.runs {
+		for i := range runs {
+			if runs[i].Id == id {
+				record := runs[i]
+				return &record
+			}
+		}
+	}
+	return nil
+}
+
+func (history *MemoryHistory) Query(filter Filter) []jobs.RunRecord {
+	history.RLock()
+	defer history.RUnlock()
+
+	var records []jobs.RunRecord
+	for jobId, runs := range history.runs {
+		if filter.JobId != "" && jobId != filter.JobId {
+			continue
+		}
+		for i := range runs {
+			if history.expired(&runs[i]) || !filter.Match(&runs[i]) {
+				continue
+			}
+			records = append(records, runs[i])
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartTime.After(records[j].StartTime)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records
+}
+
+func (history *MemoryHistory) expired(record *jobs.RunRecord) bool {
+	return history.maxAge > 0 && record.StartTime.Before(time.Now().Add(-history.maxAge))
+}
@@ -0,0 +1,162 @@
+package histories
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis"
+	"go-Job-Scheduler/jobs"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// RunsKey 执行记录 hash，按记录 id 索引
+	RunsKey = "job::runs"
+	// RunsIndexKey 所有执行记录按开始时间(毫秒)排序的有序集合
+	RunsIndexKey = "job::runs::index"
+	// JobRunsKeyPrefix 每个任务的执行记录按开始时间排序的有序集合，键为前缀加任务 id
+	JobRunsKeyPrefix = "job::runs::job::"
+)
+
+type RedisHistory struct {
+	runsKey  string
+	indexKey string
+	maxRuns  int
+	maxAge   time.Duration
+	Client   *redis.Client
+	sync.Mutex
+}
+
+func newRedisHistory() History {
+	return &RedisHistory{
+		runsKey:  RunsKey,
+		indexKey: RunsIndexKey,
+	}
+}
+
+func (history *RedisHistory) setOption(option HistoryOption) error {
+	port, _ := strconv.Atoi(option.Port)
+	db, _ := strconv.Atoi(option.DBName)
+	history.maxRuns = option.MaxRuns
+	history.maxAge = option.MaxAge
+	history.Client = redis.NewClient(&redis.Options{
+		Addr:     option.Host + ":" + strconv.Itoa(port),
+		Password: option.Password,
+		DB:       db,
+	})
+	return history.Client.Ping().Err()
+}
+
+func (history *RedisHistory) jobRunsKey(jobId string) string {
+	return JobRunsKeyPrefix + jobId
+}
+
+func millis(t time.Time) float64 {
+	return float64(t.UnixNano() / int64(time.Millisecond))
+}
+
+func (history *RedisHistory) Add(record jobs.RunRecord) error {
+	// 加锁
+	history.Lock()
+	// 函数执行完毕前解锁
+	defer history.Unlock()
+
+	score := millis(record.StartTime)
+	pipe := history.Client.Pipeline()
+	pipe.HSet(history.runsKey, record.Id, record.Bytes())
+	pipe.ZAdd(history.indexKey, redis.Z{Score: score, Member: record.Id})
+	pipe.ZAdd(history.jobRunsKey(record.JobId), redis.Z{Score: score, Member: record.Id})
+	_, err := pipe.Exec()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisHistory::Add, %s", err.Error()))
+	}
+	history.prune(record.JobId)
+	return nil
+}
+
+// prune 删除超过最长保留时间的记录，以及该任务超出最大记录数的最早的记录
+func (history *RedisHistory) prune(jobId string) {
+	var ids []string
+	if history.maxAge > 0 {
+		expired := millis(time.Now().Add(-history.maxAge))
+		ids = append(ids, history.Client.ZRangeByScore(history.indexKey, redis.ZRangeBy{
+			Min: "-inf",
+			Max: "(" + strconv.FormatFloat(expired, 'f', 0, 64),
+		}).Val()...)
+	}
+	if history.maxRuns > 0 {
+		ids = append(ids, history.Client.ZRange(history.jobRunsKey(jobId), 0, int64(-history.maxRuns-1)).Val()...)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	// 读取记录以得到所属任务
+	pipe := history.Client.Pipeline()
+	for _, record := range history.records(ids) {
+		pipe.ZRem(history.jobRunsKey(record.JobId), record.Id)
+	}
+	for _, id := range ids {
+		pipe.HDel(history.runsKey, id)
+		pipe.ZRem(history.indexKey, id)
+	}
+	_, err := pipe.Exec()
+	if err != nil {
+		log.Println("Error: RedisHistory::prune,", err)
+	}
+}
+
+// records 按 id 读取执行记录，忽略不存在的记录
+func (history *RedisHistory) records(ids []string) []jobs.RunRecord {
+	var records []jobs.RunRecord
+	if len(ids) == 0 {
+		return records
+	}
+	values, err := history.Client.HMGet(history.runsKey, ids...).Result()
+	if err != nil {
+		log.Println("Error: RedisHistory::records,", err)
+		return records
+	}
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			records = append(records, *jobs.BytesToRunRecord([]byte(s)))
+		}
+	}
+	return records
+}
+
+func (history *RedisHistory) Get(id string) *jobs.RunRecord {
+	val, err := history.Client.HGet(history.runsKey, id).Result()
+	if err != nil {
+		return nil
+	}
+	return jobs.BytesToRunRecord([]byte(val))
+}
+
+func (history *RedisHistory) Query(filter Filter) []jobs.RunRecord {
+	key := history.indexKey
+	if filter.JobId != "" {
+		key = history.jobRunsKey(filter.JobId)
+	}
+	opt := redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if !filter.From.IsZero() {
+		opt.Min = strconv.FormatFloat(millis(filter.From), 'f', 0, 64)
+	}
+	if !filter.To.IsZero() {
+		opt.Max = strconv.FormatFloat(millis(filter.To), 'f', 0, 64)
+	}
+	ids := history.Client.ZRevRangeByScore(key, opt).Val()
+
+	var records []jobs.RunRecord
+	for _, record := range history.records(ids) {
+		if !filter.Match(&record) {
+			continue
+		}
+		records = append(records, record)
+		if filter.Limit > 0 && len(records) >= filter.Limit {
+			break
+		}
+	}
+	return records
+}
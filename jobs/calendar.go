@@ -0,0 +1,209 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 任务触发时间落在日历排除范围内时的处理方式
+const (
+	// CalendarSkip 跳过该次触发，按触发器计算下一次
+	CalendarSkip = "skip"
+	// CalendarShift 顺延到日历允许的下一个时间(如下一个工作日的同一时刻)
+	CalendarShift = "shift"
+)
+
+const (
+	CalendarDateLayout = "2006-01-02"
+	CalendarTimeLayout = "15:04"
+	// calendarMaxSteps 调整触发时间时的最大尝试次数
+	calendarMaxSteps = 1000
+)
+
+// TimeRange 一天中的时间段 [Start, End)，格式 15:04，End 早于 Start 时表示跨越午夜
+type TimeRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Calendar 节假日 / 工作日历，定义需要排除的日期、星期及时间段
+type Calendar struct {
+	Name string `json:"name"`
+	// Timezone 判断日期时使用的时区，默认服务器本地时区
+	Timezone string `json:"timezone"`
+	// ExcludedDates 排除的日期，格式 2006-01-02
+	ExcludedDates []string `json:"excludedDates"`
+	// ExcludedWeekdays 排除的星期，0 周日 ... 6 周六
+	ExcludedWeekdays []int `json:"excludedWeekdays"`
+	// ExcludedTimes 每天排除的时间段
+	ExcludedTimes []TimeRange `json:"excludedTimes"`
+}
+
+// Validate 校验日历定义
+func (calendar *Calendar) Validate() error {
+	if strings.TrimSpace(calendar.Name) == "" {
+		return errors.New("calendar name must not be empty")
+	}
+	if _, err := LoadLocation(calendar.Timezone); err != nil {
+		return err
+	}
+	for _, d := range calendar.ExcludedDates {
+		if _, err := time.Parse(CalendarDateLayout, d); err != nil {
+			return errors.New(fmt.Sprintf("calendar %s: invalid excluded date %q", calendar.Name, d))
+		}
+	}
+	for _, w := range calendar.ExcludedWeekdays {
+		if w < 0 || w > 6 {
+			return errors.New(fmt.Sprintf("calendar %s: invalid excluded weekday %d", calendar.Name, w))
+		}
+	}
+	if calendar.excludesAllWeekdays() {
+		return errors.New(fmt.Sprintf("calendar %s excludes every weekday", calendar.Name))
+	}
+	for _, r := range calendar.ExcludedTimes {
+		start, err1 := time.Parse(CalendarTimeLayout, r.Start)
+		end, err2 := time.Parse(CalendarTimeLayout, r.End)
+		if err1 != nil || err2 != nil || start.Equal(end) {
+			return errors.New(fmt.Sprintf("calendar %s: invalid excluded time range %s-%s", calendar.Name, r.Start, r.End))
+		}
+	}
+	return nil
+}
+
+func (calendar *Calendar) excludesAllWeekdays() bool {
+	seen := make(map[int]bool)
+	for _, w := range calendar.ExcludedWeekdays {
+		seen[w] = true
+	}
+	return len(seen) == 7
+}
+
+// Location 返回日历所在时区
+func (calendar *Calendar) Location() *time.Location {
+	loc, err := LoadLocation(calendar.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Excludes 判断时刻 t 是否被日历排除
+func (calendar *Calendar) Excludes(t time.Time) bool {
+	local := t.In(calendar.Location())
+	return calendar.excludesDay(local) || calendar.excludedRangeEnd(local) != nil
+}
+
+func (calendar *Calendar) excludesDay(local time.Time) bool {
+	date := local.Format(CalendarDateLayout)
+	for _, d := range calendar.ExcludedDates {
+		if d == date {
+			return true
+		}
+	}
+	for _, w := range calendar.ExcludedWeekdays {
+		if time.Weekday(w) == local.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedRangeEnd 若 local 落在排除的时间段内，返回该时间段的结束时刻
+func (calendar *Calendar) excludedRangeEnd(local time.Time) *time.Time {
+	minutes := local.Hour()*60 + local.Minute()
+	for _, r := range calendar.ExcludedTimes {
+		start, err1 := time.Parse(CalendarTimeLayout, r.Start)
+		end, err2 := time.Parse(CalendarTimeLayout, r.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		s, e := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+		var endDay int
+		switch {
+		case s < e && minutes >= s && minutes < e:
+			endDay = 0
+		case s > e && minutes >= s:
+			endDay = 1
+		case s > e && minutes < e:
+			endDay = 0
+		default:
+			continue
+		}
+		wall := time.Date(local.Year(), local.Month(), local.Day()+endDay, end.Hour(), end.Minute(), 0, 0, time.UTC)
+		t := ResolveWallClock(wall, local.Location())
+		return &t
+	}
+	return nil
+}
+
+// NextIncluded 返回不早于 t 且不被日历排除的最早时刻: 被排除的日期顺延到下一天的同一时刻，
+// 被排除的时间段顺延到时间段结束
+func (calendar *Calendar) NextIncluded(t time.Time) (time.Time, bool) {
+	loc := calendar.Location()
+	local := t.In(loc)
+	for i := 0; i < calendarMaxSteps; i++ {
+		if calendar.excludesDay(local) {
+			wall := time.Date(local.Year(), local.Month(), local.Day()+1, local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+			local = ResolveWallClock(wall, loc)
+			continue
+		}
+		if end := calendar.excludedRangeEnd(local); end != nil {
+			local = *end
+			continue
+		}
+		return local, true
+	}
+	return time.Time{}, false
+}
+
+// ApplyCalendar 按日历调整任务的下次触发时间
+func (job *Job) ApplyCalendar(calendar *Calendar) error {
+	if calendar == nil || job.ScheduledTime().Unix() == 0 {
+		return nil
+	}
+	trigger, err := job.NewTrigger()
+	if err != nil {
+		return err
+	}
+	next := job.ScheduledTime()
+	for i := 0; i < calendarMaxSteps; i++ {
+		if !calendar.Excludes(next) {
+			job.SetScheduledTime(next)
+			return nil
+		}
+		if job.CalendarPolicy == CalendarShift {
+			shifted, ok := calendar.NextIncluded(next)
+			if !ok {
+				break
+			}
+			job.SetScheduledTime(shifted)
+			return nil
+		}
+		var ok bool
+		if next, ok = trigger.NextFireTime(next, time.Now()); !ok {
+			// 触发器不再触发
+			job.SetScheduledTime(time.Unix(0, 0))
+			return nil
+		}
+	}
+	job.SetScheduledTime(time.Unix(0, 0))
+	return errors.New(fmt.Sprintf("calendar %s excludes every fire time of job %s", calendar.Name, job.Id))
+}
+
+func (calendar *Calendar) Bytes() []byte {
+	// 使用 encoding/gob 序列化
+	buf := new(bytes.Buffer)
+	_ = gob.NewEncoder(buf).Encode(calendar)
+	return buf.Bytes()
+}
+
+func BytesToCalendar(b []byte) *Calendar {
+	// 使用 encoding/gob 反序列化
+	var calendar Calendar
+	_ = gob.NewDecoder(bytes.NewBuffer(b)).Decode(&calendar)
+	return &calendar
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 2022-10-01 为周六
+var testCalendar = Calendar{
+	Name:             "workdays",
+	Timezone:         "UTC",
+	ExcludedDates:    []string{"2022-10-03"},
+	ExcludedWeekdays: []int{0, 6},
+	ExcludedTimes:    []TimeRange{{Start: "12:00", End: "13:00"}, {Start: "22:00", End: "06:00"}},
+}
+
+func TestCalendarExcludes(t *testing.T) {
+	tests := []struct {
+		at       time.Time
+		excluded bool
+	}{
+		{utc(2022, 9, 30, 9, 0, 0), false},
+		// 排除的星期
+		{utc(2022, 10, 1, 9, 0, 0), true},
+		{utc(2022, 10, 2, 9, 0, 0), true},
+		// 排除的日期
+		{utc(2022, 10, 3, 9, 0, 0), true},
+		{utc(2022, 10, 4, 9, 0, 0), false},
+		// 排除的时间段 [Start, End)
+		{utc(2022, 10, 4, 11, 59, 0), false},
+		{utc(2022, 10, 4, 12, 0, 0), true},
+		{utc(2022, 10, 4, 12, 59, 0), true},
+		{utc(2022, 10, 4, 13, 0, 0), false},
+		// 跨越午夜的时间段
+		{utc(2022, 10, 4, 21, 59, 0), false},
+		{utc(2022, 10, 4, 23, 0, 0), true},
+		{utc(2022, 10, 5, 5, 59, 0), true},
+		{utc(2022, 10, 5, 6, 0, 0), false},
+	}
+	for _, test := range tests {
+		if got := testCalendar.Excludes(test.at); got != test.excluded {
+			t.Fatalf("%s: excluded %v, want %v", test.at, got, test.excluded)
+		}
+	}
+
+	// 按日历时区判断日期
+	shanghai := Calendar{Name: "cn", Timezone: "Asia/Shanghai", ExcludedDates: []string{"2022-10-01"}}
+	mustLoadLocation(t, "Asia/Shanghai")
+	if !shanghai.Excludes(utc(2022, 9, 30, 17, 0, 0)) || shanghai.Excludes(utc(2022, 9, 30, 15, 0, 0)) {
+		t.Fatal("excluded date not evaluated in the calendar's time zone")
+	}
+}
+
+func TestCalendarNextIncluded(t *testing.T) {
+	tests := []struct {
+		at, want time.Time
+	}{
+		{utc(2022, 10, 4, 9, 0, 0), utc(2022, 10, 4, 9, 0, 0)},
+		// 周末及排除的日期顺延到下一天的同一时刻
+		{utc(2022, 10, 1, 9, 0, 0), utc(2022, 10, 4, 9, 0, 0)},
+		// 时间段顺延到结束时刻
+		{utc(2022, 10, 4, 12, 30, 0), utc(2022, 10, 4, 13, 0, 0)},
+		{utc(2022, 10, 4, 23, 0, 0), utc(2022, 10, 5, 6, 0, 0)},
+		// 周五夜间顺延到周一被排除，再顺延到周二
+		{utc(2022, 9, 30, 23, 0, 0), utc(2022, 10, 4, 6, 0, 0)},
+	}
+	for _, test := range tests {
+		got, ok := testCalendar.NextIncluded(test.at)
+		if !ok || !got.Equal(test.want) {
+			t.Fatalf("%s: next included %s (ok %v), want %s", test.at, got, ok, test.want)
+		}
+	}
+}
+
+func TestApplyCalendarPolicies(t *testing.T) {
+	tests := []struct {
+		cron      string
+		policy    string
+		scheduled time.Time
+		want      time.Time
+	}{
+		// 每周三、周六 9 点: skip 跳到下一次触发(周三)，shift 顺延到下一个允许的日期(周二)
+		{"0 9 * * 3,6", CalendarSkip, utc(2022, 10, 1, 9, 0, 0), utc(2022, 10, 5, 9, 0, 0)},
+		{"0 9 * * 3,6", CalendarShift, utc(2022, 10, 1, 9, 0, 0), utc(2022, 10, 4, 9, 0, 0)},
+		// 每小时 30 分: skip 跳到 13:30，shift 顺延到时间段结束的 13:00
+		{"30 * * * *", CalendarSkip, utc(2022, 10, 4, 12, 30, 0), utc(2022, 10, 4, 13, 30, 0)},
+		{"30 * * * *", CalendarShift, utc(2022, 10, 4, 12, 30, 0), utc(2022, 10, 4, 13, 0, 0)},
+		// 未被排除时不调整
+		{"30 * * * *", CalendarSkip, utc(2022, 10, 4, 10, 30, 0), utc(2022, 10, 4, 10, 30, 0)},
+	}
+	for _, test := range tests {
+		job := newTestJob(t, `{"funcName": "add", "type": 4, "timezone": "UTC", "startTime": "2022-09-30T00:00:00Z", "cron": "`+test.cron+`"}`)
+		job.Calendar, job.CalendarPolicy = testCalendar.Name, test.policy
+		job.SetScheduledTime(test.scheduled)
+		if err := job.ApplyCalendar(&testCalendar); err != nil {
+			t.Fatal(err)
+		}
+		if !job.ScheduledTime().Equal(test.want) {
+			t.Fatalf("%s %s from %s: scheduled at %s, want %s", test.cron, test.policy, test.scheduled, job.ScheduledTime(), test.want)
+		}
+	}
+
+	// 日历排除所有触发时间
+	job := newTestJob(t, `{"funcName": "add", "type": 4, "timezone": "UTC", "startTime": "2022-09-30T00:00:00Z", "cron": "0 9 * * SAT"}`)
+	job.SetScheduledTime(utc(2022, 10, 1, 9, 0, 0))
+	if err := job.ApplyCalendar(&testCalendar); err == nil || job.ScheduledTime().Unix() != 0 {
+		t.Fatalf("err %v, scheduled at %s", err, job.ScheduledTime())
+	}
+}
+
+func TestCalendarValidate(t *testing.T) {
+	if err := testCalendar.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		calendar Calendar
+		message  string
+	}{
+		{Calendar{Name: " "}, "name"},
+		{Calendar{Name: "c", Timezone: "Mars/Olympus"}, "timezone"},
+		{Calendar{Name: "c", ExcludedDates: []string{"2022-13-01"}}, "excluded date"},
+		{Calendar{Name: "c", ExcludedWeekdays: []int{7}}, "excluded weekday"},
+		{Calendar{Name: "c", ExcludedWeekdays: []int{0, 1, 2, 3, 4, 5, 6}}, "every weekday"},
+		{Calendar{Name: "c", ExcludedTimes: []TimeRange{{Start: "12:00", End: "12:00"}}}, "time range"},
+		{Calendar{Name: "c", ExcludedTimes: []TimeRange{{Start: "25:00", End: "12:00"}}}, "time range"},
+	}
+	for _, test := range tests {
+		err := test.calendar.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Fatalf("%+v: err %v, want %q", test.calendar, err, test.message)
+		}
+	}
+}
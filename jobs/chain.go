@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ResultArg 将上一个任务的第 Result 个返回值填入后续任务的第 Arg 个参数(均从0开始)
+type ResultArg struct {
+	Result int `json:"result"`
+	Arg    int `json:"arg"`
+}
+
+// validateChain 校验 onSuccess 后续任务的定义
+func (job *Job) validateChain() error {
+	for next := job.OnSuccess; next != nil; next = next.OnSuccess {
+		if next.KindOf() == KindFunc && next.FuncName == "" {
+			return errors.New("onSuccess job requires a funcName")
+		}
+		if err := next.validateKind(); err != nil {
+			return errors.New(fmt.Sprintf("onSuccess job: %s", err.Error()))
+		}
+		if err := next.validateRouting(); err != nil {
+			return errors.New(fmt.Sprintf("onSuccess job: %s", err.Error()))
+		}
+		for _, ra := range next.ResultArgs {
+			if ra.Result < 0 || ra.Arg < 0 {
+				return errors.New(fmt.Sprintf("invalid result arg %d -> %d", ra.Result, ra.Arg))
+			}
+		}
+	}
+	return nil
+}
+
+// FollowUp 按 ResultArgs 用本次执行的返回值填充 onSuccess 后续任务的参数，返回要执行的后续任务。
+// 参数个数不足时以 nil 补齐，后续任务 id 为空时使用 "<本任务 id>/onSuccess"，
+// 未设置 queue 及 labels 时沿用本任务的
+func (job *Job) FollowUp(results []interface{}) (Job, error) {
+	if job.OnSuccess == nil {
+		return Job{}, errors.New(fmt.Sprintf("job %s has no onSuccess job", job.Id))
+	}
+	next := *job.OnSuccess
+	if next.Id == "" {
+		next.Id = job.Id + "/onSuccess"
+	}
+	if next.Queue == "" && next.Labels == nil {
+		next.Queue = job.Queue
+		next.Labels = job.Labels
+	}
+	args := append([]interface{}{}, next.Args...)
+	for _, ra := range next.ResultArgs {
+		if ra.Result >= len(results) {
+			return Job{}, errors.New(fmt.Sprintf("job %s returned %d values, onSuccess job needs result %d", job.Id, len(results), ra.Result))
+		}
+		for len(args) <= ra.Arg {
+			args = append(args, nil)
+		}
+		args[ra.Arg] = results[ra.Result]
+	}
+	next.Args = args
+	return next, nil
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 组合触发器名称
+const (
+	TriggerAnd = "and"
+	TriggerOr  = "or"
+)
+
+// andTriggerMaxIterations and 触发器查找共同触发时间时的最大迭代次数
+const andTriggerMaxIterations = 10000
+
+// andTrigger 在所有子触发器都触发的最早时刻触发，如 "每15分钟" 且 "工作时间内"
+type andTrigger struct {
+	triggers []Trigger
+}
+
+// orTrigger 在任一子触发器触发时触发，多个子触发器同时触发时只触发一次
+type orTrigger struct {
+	triggers []Trigger
+}
+
+func newChildTriggers(job *Job, spec TriggerSpec) ([]Trigger, error) {
+	if len(spec.Triggers) == 0 {
+		return nil, errors.New(fmt.Sprintf("%s trigger requires at least one child trigger", spec.Type))
+	}
+	children := make([]Trigger, 0, len(spec.Triggers))
+	for i, childSpec := range spec.Triggers {
+		child, err := NewTrigger(job, childSpec)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s trigger: child %d: %s", spec.Type, i, err.Error()))
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+func newAndTrigger(job *Job, spec TriggerSpec) (Trigger, error) {
+	children, err := newChildTriggers(job, spec)
+	if err != nil {
+		return nil, err
+	}
+	t := &andTrigger{triggers: children}
+	// 提交时校验子触发器存在共同的触发时间
+	if _, ok := t.NextFireTime(time.Time{}, time.Now()); !ok {
+		return nil, errors.New("and trigger: child triggers never fire at the same time")
+	}
+	return t, nil
+}
+
+func newOrTrigger(job *Job, spec TriggerSpec) (Trigger, error) {
+	children, err := newChildTriggers(job, spec)
+	if err != nil {
+		return nil, err
+	}
+	return &orTrigger{triggers: children}, nil
+}
+
+func (t *andTrigger) NextFireTime(prev, now time.Time) (time.Time, bool) {
+	var candidate time.Time
+	for _, child := range t.triggers {
+		next, ok := child.NextFireTime(prev, now)
+		if !ok {
+			return time.Time{}, false
+		}
+		if next.After(candidate) {
+			candidate = next
+		}
+	}
+
+	for i := 0; i < andTriggerMaxIterations; i++ {
+		matched := true
+		var latest time.Time
+		for _, child := range t.triggers {
+			// 查找不早于 candidate 的触发时间
+			next, ok := child.NextFireTime(candidate.Add(-time.Nanosecond), now)
+			if !ok {
+				return time.Time{}, false
+			}
+			if !next.Equal(candidate) {
+				matched = false
+			}
+			if next.After(latest) {
+				latest = next
+			}
+		}
+		if matched {
+			return candidate, true
+		}
+		candidate = latest
+	}
+	return time.Time{}, false
+}
+
+func (t *orTrigger) NextFireTime(prev, now time.Time) (time.Time, bool) {
+	var earliest time.Time
+	found := false
+	for _, child := range t.triggers {
+		next, ok := child.NextFireTime(prev, now)
+		if !ok {
+			continue
+		}
+		if !found || next.Before(earliest) {
+			earliest = next
+			found = true
+		}
+	}
+	return earliest, found
+}
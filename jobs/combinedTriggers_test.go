@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fireTimes 返回触发器从首次触发起的前 n 次触发时间
+func fireTimes(trigger Trigger, n int) []time.Time {
+	var times []time.Time
+	var prev time.Time
+	for i := 0; i < n; i++ {
+		next, ok := trigger.NextFireTime(prev, prev)
+		if !ok {
+			break
+		}
+		times = append(times, next)
+		prev = next
+	}
+	return times
+}
+
+func TestAndTrigger(t *testing.T) {
+	// 每 15 分钟且在工作日 9-17 点整点
+	job := newTestJob(t, `{"funcName": "add", "timezone": "UTC", "startTime": "2022-01-01T00:00:00Z", "trigger": {"type": "and", "triggers": [
+		{"type": "interval", "options": {"interval": "15m"}},
+		{"type": "cron", "options": {"expr": "0 9-17 * * MON-FRI"}}]}}`)
+	trigger, err := job.NewTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{utc(2022, 1, 3, 9, 0, 0), utc(2022, 1, 3, 10, 0, 0), utc(2022, 1, 3, 11, 0, 0)}
+	got := fireTimes(trigger, len(want))
+	if len(got) != len(want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("fire %d at %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAndTriggerNeverIntersects(t *testing.T) {
+	start := utc(2022, 1, 1, 0, 0, 0)
+	trigger := &andTrigger{triggers: []Trigger{
+		&intervalTrigger{startDate: start, interval: time.Hour},
+		&intervalTrigger{startDate: start.Add(30 * time.Minute), interval: time.Hour},
+	}}
+	begin := time.Now()
+	if next, ok := trigger.NextFireTime(time.Time{}, start); ok {
+		t.Fatalf("fired at %s", next)
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("search took %s", elapsed)
+	}
+
+	// 提交时拒绝不存在共同触发时间的 and 触发器
+	for _, data := range []string{
+		`{"funcName": "add", "timezone": "UTC", "startTime": "2022-01-01T00:00:00Z", "trigger": {"type": "and", "triggers": [
+			{"type": "cron", "options": {"expr": "0 0 * * MON"}}, {"type": "cron", "options": {"expr": "0 0 * * TUE"}}]}}`,
+		`{"funcName": "add", "timezone": "UTC", "startTime": "2022-01-01T00:00:00Z", "trigger": {"type": "and", "triggers": [
+			{"type": "date", "options": {"runDate": "2022-01-01T10:00:00Z"}}, {"type": "cron", "options": {"expr": "0 12 * * *"}}]}}`,
+	} {
+		job := Job{}
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			t.Fatal(err)
+		}
+		if err := job.Init(); err == nil || !strings.Contains(err.Error(), "never fire at the same time") {
+			t.Fatalf("Init returned %v", err)
+		}
+	}
+}
+
+func TestCombinedTriggerValidation(t *testing.T) {
+	for _, data := range []string{
+		`{"type": "and"}`,
+		`{"type": "or", "triggers": []}`,
+		`{"type": "or", "triggers": [{"type": "cron", "options": {"expr": "bad"}}]}`,
+		`{"type": "and", "triggers": [{"type": "interval"}, {"type": "unknown"}]}`,
+	} {
+		var spec TriggerSpec
+		if err := json.Unmarshal([]byte(data), &spec); err != nil {
+			t.Fatal(err)
+		}
+		job := &Job{StartTime: utc(2022, 1, 1, 0, 0, 0), Timezone: "UTC"}
+		if _, err := NewTrigger(job, spec); err == nil {
+			t.Fatalf("%s: expected an error", data)
+		}
+	}
+}
+
+func TestOrTrigger(t *testing.T) {
+	// 一次性触发与每天 12 点触发，同时触发时只触发一次
+	for _, runDate := range []string{"2022-01-01T10:30:00Z", "2022-01-02T12:00:00Z"} {
+		job := newTestJob(t, `{"funcName": "add", "timezone": "UTC", "startTime": "2022-01-01T00:00:00Z", "trigger": {"type": "or", "triggers": [
+			{"type": "date", "options": {"runDate": "`+runDate+`"}},
+			{"type": "cron", "options": {"expr": "0 12 * * *"}}]}}`)
+		trigger, err := job.NewTrigger()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []time.Time{utc(2022, 1, 1, 12, 0, 0), utc(2022, 1, 2, 12, 0, 0), utc(2022, 1, 3, 12, 0, 0)}
+		if runDate == "2022-01-01T10:30:00Z" {
+			want = append([]time.Time{utc(2022, 1, 1, 10, 30, 0)}, want...)
+		}
+		got := fireTimes(trigger, len(want))
+		if len(got) != len(want) {
+			t.Fatalf("runDate %s: fired %v, want %v", runDate, got, want)
+		}
+		for i := range want {
+			if !got[i].Equal(want[i]) {
+				t.Fatalf("runDate %s: fire %d at %s, want %s", runDate, i, got[i], want[i])
+			}
+		}
+	}
+}
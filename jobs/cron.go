@@ -0,0 +1,450 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron 表达式支持两种格式:
+//   5 个字段: 分 时 日 月 周
+//   6 个字段: 秒 分 时 日 月 周
+// 每个字段支持 * ? , - / 以及:
+//   日: L (月末), L-n (月末前第n天), nW (离n号最近的工作日), LW (月末最后一个工作日)
+//   周: nL (本月最后一个周n), n#k (本月第k个周n), 周取值 0-7, 0 与 7 均为周日
+// 月与周可以使用英文缩写, 如 JAN-DEC, SUN-SAT
+
+const (
+	// cronSearchYears 查找下次触发时间时最多向后搜索的年数
+	cronSearchYears = 5
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var weekdayNames = map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	secondField = cronField{"second", 0, 59, nil}
+	minuteField = cronField{"minute", 0, 59, nil}
+	hourField   = cronField{"hour", 0, 23, nil}
+	domField    = cronField{"day of month", 1, 31, nil}
+	monthField  = cronField{"month", 1, 12, monthNames}
+	dowField    = cronField{"day of week", 0, 7, weekdayNames}
+)
+
+// nthWeekday 表示本月第 nth 个周 weekday
+type nthWeekday struct {
+	weekday int
+	nth     int
+}
+
+// CronSchedule 解析后的 cron 表达式
+type CronSchedule struct {
+	Expr   string
+	second uint64
+	minute uint64
+	hour   uint64
+	month  uint64
+
+	dom        uint64
+	domStar    bool
+	domLast    []int // L, L-n 中的 n
+	domNearest []int // nW 中的 n
+	domLastW   bool  // LW
+
+	dow     uint64
+	dowStar bool
+	dowLast []int // nL 中的 n
+	dowNth  []nthWeekday
+}
+
+// ParseCron 解析 cron 表达式
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if spec == "" {
+		return nil, errors.New("cron: empty expression")
+	}
+	if v, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = v
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, errors.New(fmt.Sprintf("cron: expected 5 or 6 fields, got %d in %q", len(fields), expr))
+	}
+
+	s := &CronSchedule{Expr: expr}
+	var err error
+	if s.second, _, err = parseCronField(fields[0], secondField); err != nil {
+		return nil, err
+	}
+	if s.minute, _, err = parseCronField(fields[1], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, _, err = parseCronField(fields[2], hourField); err != nil {
+		return nil, err
+	}
+	if err = s.parseDom(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.month, _, err = parseCronField(fields[4], monthField); err != nil {
+		return nil, err
+	}
+	if err = s.parseDow(fields[5]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *CronSchedule) parseDom(field string) error {
+	var rest []string
+	for _, item := range strings.Split(field, ",") {
+		upper := strings.ToUpper(item)
+		switch {
+		case upper == "L":
+			s.domLast = append(s.domLast, 0)
+		case upper == "LW":
+			s.domLastW = true
+		case strings.HasPrefix(upper, "L-"):
+			n, err := strconv.Atoi(upper[2:])
+			if err != nil || n < 0 || n > 30 {
+				return errors.New(fmt.Sprintf("cron: invalid day of month offset %q", item))
+			}
+			s.domLast = append(s.domLast, n)
+		case strings.HasSuffix(upper, "W"):
+			n, err := strconv.Atoi(upper[:len(upper)-1])
+			if err != nil || n < domField.min || n > domField.max {
+				return errors.New(fmt.Sprintf("cron: invalid nearest weekday %q", item))
+			}
+			s.domNearest = append(s.domNearest, n)
+		default:
+			rest = append(rest, item)
+		}
+	}
+	if len(rest) > 0 {
+		bits, star, err := parseCronField(strings.Join(rest, ","), domField)
+		if err != nil {
+			return err
+		}
+		s.dom = bits
+		s.domStar = star && len(rest) == len(strings.Split(field, ","))
+	}
+	return nil
+}
+
+func (s *CronSchedule) parseDow(field string) error {
+	var rest []string
+	for _, item := range strings.Split(field, ",") {
+		upper := strings.ToUpper(item)
+		switch {
+		case strings.Contains(upper, "#"):
+			parts := strings.SplitN(upper, "#", 2)
+			weekday, err := parseCronValue(parts[0], dowField)
+			if err != nil {
+				return err
+			}
+			nth, err := strconv.Atoi(parts[1])
+			if err != nil || nth < 1 || nth > 5 {
+				return errors.New(fmt.Sprintf("cron: invalid nth weekday %q", item))
+			}
+			s.dowNth = append(s.dowNth, nthWeekday{weekday: weekday % 7, nth: nth})
+		case len(upper) > 1 && strings.HasSuffix(upper, "L"):
+			weekday, err := parseCronValue(upper[:len(upper)-1], dowField)
+			if err != nil {
+				return err
+			}
+			s.dowLast = append(s.dowLast, weekday%7)
+		default:
+			rest = append(rest, item)
+		}
+	}
+	if len(rest) > 0 {
+		bits, star, err := parseCronField(strings.Join(rest, ","), dowField)
+		if err != nil {
+			return err
+		}
+		// 7 与 0 均表示周日
+		if bits&(1<<7) != 0 {
+			bits = bits&^(1<<7) | 1
+		}
+		s.dow = bits
+		s.dowStar = star && len(rest) == len(strings.Split(field, ","))
+	}
+	return nil
+}
+
+// parseCronField 解析一个字段, 返回取值位图以及是否为 * 或 ?
+func parseCronField(field string, f cronField) (uint64, bool, error) {
+	var bits uint64
+	star := false
+	for _, item := range strings.Split(field, ",") {
+		b, isStar, err := parseCronItem(item, f)
+		if err != nil {
+			return 0, false, err
+		}
+		bits |= b
+		star = star || isStar
+	}
+	return bits, star, nil
+}
+
+func parseCronItem(item string, f cronField) (uint64, bool, error) {
+	if item == "" {
+		return 0, false, errors.New(fmt.Sprintf("cron: empty %s item", f.name))
+	}
+	rangePart, step := item, 1
+	hasStep := false
+	if i := strings.Index(item, "/"); i >= 0 {
+		var err error
+		rangePart = item[:i]
+		step, err = strconv.Atoi(item[i+1:])
+		if err != nil || step <= 0 {
+			return 0, false, errors.New(fmt.Sprintf("cron: invalid step in %s item %q", f.name, item))
+		}
+		hasStep = true
+	}
+
+	var start, end int
+	star := false
+	switch {
+	case rangePart == "*" || rangePart == "?":
+		start, end = f.min, f.max
+		star = !hasStep
+	case strings.Contains(rangePart, "-"):
+		parts := strings.SplitN(rangePart, "-", 2)
+		var err error
+		if start, err = parseCronValue(parts[0], f); err != nil {
+			return 0, false, err
+		}
+		if end, err = parseCronValue(parts[1], f); err != nil {
+			return 0, false, err
+		}
+	default:
+		var err error
+		if start, err = parseCronValue(rangePart, f); err != nil {
+			return 0, false, err
+		}
+		end = start
+		// a/n 表示从 a 开始到最大值
+		if hasStep {
+			end = f.max
+		}
+	}
+	if start > end {
+		return 0, false, errors.New(fmt.Sprintf("cron: invalid %s range %q", f.name, item))
+	}
+
+	var bits uint64
+	for v := start; v <= end; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, star, nil
+}
+
+func parseCronValue(s string, f cronField) (int, error) {
+	if f.names != nil {
+		if v, ok := f.names[strings.ToUpper(s)]; ok {
+			return v, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("cron: invalid %s value %q", f.name, s))
+	}
+	if v < f.min || v > f.max {
+		return 0, errors.New(fmt.Sprintf("cron: %s value %d out of range [%d, %d]", f.name, v, f.min, f.max))
+	}
+	return v, nil
+}
+
+// Next 返回严格晚于 t 的下一次触发时间, 按 t 所在时区的挂钟时间匹配表达式,
+// 若在搜索范围内找不到则返回 false
+func (s *CronSchedule) Next(t time.Time) (time.Time, bool) {
+	loc := t.Location()
+	// 使用 UTC 表示挂钟时间进行计算, 避免时区偏移影响日期运算
+	w := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	w = w.Add(time.Second)
+	yearLimit := w.Year() + cronSearchYears
+
+WRAP:
+	if w.Year() > yearLimit {
+		return time.Time{}, false
+	}
+
+	for !hasBit(s.month, int(w.Month())) {
+		w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		if w.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !s.dayMatches(w) {
+		w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+		if w.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for !hasBit(s.hour, w.Hour()) {
+		w = w.Truncate(time.Hour).Add(time.Hour)
+		if w.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for !hasBit(s.minute, w.Minute()) {
+		w = w.Truncate(time.Minute).Add(time.Minute)
+		if w.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for !hasBit(s.second, w.Second()) {
+		w = w.Add(time.Second)
+		if w.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	// 夏令时切换: 被跳过的时刻顺延, 重复的时刻只触发一次
+	next := ResolveWallClock(w, loc)
+	if !next.After(t) {
+		w = w.Add(time.Second)
+		goto WRAP
+	}
+	return next, true
+}
+
+func (s *CronSchedule) dayMatches(w time.Time) bool {
+	domMatch := s.domMatches(w)
+	dowMatch := s.dowMatches(w)
+	// 与 vixie cron 一致: 日与周同时被限定时, 满足其一即可
+	if s.domRestricted() && s.dowRestricted() {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+func (s *CronSchedule) domRestricted() bool {
+	return !s.domStar && (s.dom != 0 || len(s.domLast) > 0 || len(s.domNearest) > 0 || s.domLastW)
+}
+
+func (s *CronSchedule) dowRestricted() bool {
+	return !s.dowStar && (s.dow != 0 || len(s.dowLast) > 0 || len(s.dowNth) > 0)
+}
+
+func (s *CronSchedule) domMatches(w time.Time) bool {
+	if !s.domRestricted() {
+		return true
+	}
+	day := w.Day()
+	if hasBit(s.dom, day) {
+		return true
+	}
+	last := daysIn(w.Year(), w.Month())
+	for _, n := range s.domLast {
+		if day == last-n {
+			return true
+		}
+	}
+	for _, n := range s.domNearest {
+		if day == nearestWeekday(w.Year(), w.Month(), n) {
+			return true
+		}
+	}
+	if s.domLastW && day == lastWeekday(w.Year(), w.Month()) {
+		return true
+	}
+	return false
+}
+
+func (s *CronSchedule) dowMatches(w time.Time) bool {
+	if !s.dowRestricted() {
+		return true
+	}
+	weekday := int(w.Weekday())
+	if hasBit(s.dow, weekday) {
+		return true
+	}
+	day := w.Day()
+	last := daysIn(w.Year(), w.Month())
+	for _, wd := range s.dowLast {
+		if wd == weekday && day+7 > last {
+			return true
+		}
+	}
+	for _, nth := range s.dowNth {
+		if nth.weekday == weekday && (day-1)/7+1 == nth.nth {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBit(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// nearestWeekday 返回离 day 号最近的工作日, 不跨月; 当月没有 day 号时返回 -1
+func nearestWeekday(year int, month time.Month, day int) int {
+	last := daysIn(year, month)
+	if day > last {
+		return -1
+	}
+	switch time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+// lastWeekday 返回当月最后一个工作日
+func lastWeekday(year int, month time.Month) int {
+	last := daysIn(year, month)
+	switch time.Date(year, month, last, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		return last - 1
+	case time.Sunday:
+		return last - 2
+	}
+	return last
+}
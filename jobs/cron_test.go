@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func utc(year int, month time.Month, day, hour, min, sec int) time.Time {
+	return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+}
+
+func TestCronNext(t *testing.T) {
+	jan1 := utc(2022, 1, 1, 0, 0, 0) // 周六
+	tests := []struct {
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{"*/15 * * * *", jan1, []time.Time{utc(2022, 1, 1, 0, 15, 0), utc(2022, 1, 1, 0, 30, 0), utc(2022, 1, 1, 0, 45, 0)}},
+		{"0 9-17/4 * * *", jan1, []time.Time{utc(2022, 1, 1, 9, 0, 0), utc(2022, 1, 1, 13, 0, 0), utc(2022, 1, 1, 17, 0, 0)}},
+		{"30 8 * * MON-FRI", jan1, []time.Time{utc(2022, 1, 3, 8, 30, 0), utc(2022, 1, 4, 8, 30, 0)}},
+		{"0 0 ? * MON", jan1, []time.Time{utc(2022, 1, 3, 0, 0, 0), utc(2022, 1, 10, 0, 0, 0)}},
+		// 6 个字段时第一个字段为秒
+		{"*/20 0 0 * * *", jan1, []time.Time{utc(2022, 1, 1, 0, 0, 20), utc(2022, 1, 1, 0, 0, 40), utc(2022, 1, 2, 0, 0, 0)}},
+		{"0 0 1 JAN,JUL *", jan1, []time.Time{utc(2022, 7, 1, 0, 0, 0), utc(2023, 1, 1, 0, 0, 0)}},
+		// 0 与 7 均为周日
+		{"0 0 * * 7", jan1, []time.Time{utc(2022, 1, 2, 0, 0, 0), utc(2022, 1, 9, 0, 0, 0)}},
+		// 日与周同时被限定时满足其一即可
+		{"0 0 13 * FRI", jan1, []time.Time{utc(2022, 1, 7, 0, 0, 0), utc(2022, 1, 13, 0, 0, 0), utc(2022, 1, 14, 0, 0, 0)}},
+		{"0 0 L * *", jan1, []time.Time{utc(2022, 1, 31, 0, 0, 0), utc(2022, 2, 28, 0, 0, 0), utc(2022, 3, 31, 0, 0, 0)}},
+		{"0 0 L-2 * *", jan1, []time.Time{utc(2022, 1, 29, 0, 0, 0), utc(2022, 2, 26, 0, 0, 0), utc(2022, 3, 29, 0, 0, 0)}},
+		// 1 号为周六时顺延到周一，不跨到上个月
+		{"0 0 1W * *", jan1, []time.Time{utc(2022, 1, 3, 0, 0, 0), utc(2022, 2, 1, 0, 0, 0)}},
+		// 15 号为周六时提前到周五
+		{"0 0 15W * *", jan1, []time.Time{utc(2022, 1, 14, 0, 0, 0), utc(2022, 2, 15, 0, 0, 0)}},
+		// 31 号为周日时提前到周五；没有 31 号的月份不触发
+		{"0 0 31W * *", utc(2022, 6, 1, 0, 0, 0), []time.Time{utc(2022, 7, 29, 0, 0, 0), utc(2022, 8, 31, 0, 0, 0), utc(2022, 10, 31, 0, 0, 0)}},
+		{"0 0 LW * *", utc(2022, 3, 1, 0, 0, 0), []time.Time{utc(2022, 3, 31, 0, 0, 0), utc(2022, 4, 29, 0, 0, 0), utc(2022, 5, 31, 0, 0, 0)}},
+		{"0 0 * * 5L", jan1, []time.Time{utc(2022, 1, 28, 0, 0, 0), utc(2022, 2, 25, 0, 0, 0), utc(2022, 3, 25, 0, 0, 0)}},
+		{"0 0 * * 1#2", jan1, []time.Time{utc(2022, 1, 10, 0, 0, 0), utc(2022, 2, 14, 0, 0, 0), utc(2022, 3, 14, 0, 0, 0)}},
+		// 没有第 5 个周日的月份不触发
+		{"0 0 * * SUN#5", jan1, []time.Time{utc(2022, 1, 30, 0, 0, 0), utc(2022, 5, 29, 0, 0, 0)}},
+		{"0 0 31 * *", jan1, []time.Time{utc(2022, 1, 31, 0, 0, 0), utc(2022, 3, 31, 0, 0, 0), utc(2022, 5, 31, 0, 0, 0)}},
+		// 闰年
+		{"0 0 29 2 *", jan1, []time.Time{utc(2024, 2, 29, 0, 0, 0), utc(2028, 2, 29, 0, 0, 0)}},
+		{"0 0 L 2 *", jan1, []time.Time{utc(2022, 2, 28, 0, 0, 0), utc(2023, 2, 28, 0, 0, 0), utc(2024, 2, 29, 0, 0, 0)}},
+		{"@yearly", jan1, []time.Time{utc(2023, 1, 1, 0, 0, 0), utc(2024, 1, 1, 0, 0, 0)}},
+		{"@monthly", jan1, []time.Time{utc(2022, 2, 1, 0, 0, 0), utc(2022, 3, 1, 0, 0, 0)}},
+		{"@weekly", jan1, []time.Time{utc(2022, 1, 2, 0, 0, 0), utc(2022, 1, 9, 0, 0, 0)}},
+		{"@daily", jan1, []time.Time{utc(2022, 1, 2, 0, 0, 0)}},
+		{"@hourly", jan1, []time.Time{utc(2022, 1, 1, 1, 0, 0)}},
+	}
+	for _, test := range tests {
+		schedule, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expr, err)
+		}
+		prev := test.from
+		for i, want := range test.want {
+			next, ok := schedule.Next(prev)
+			if !ok || !next.Equal(want) {
+				t.Fatalf("%s: fire %d at %s (ok %v), want %s", test.expr, i, next, ok, want)
+			}
+			prev = next
+		}
+	}
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	// 表达式合法但在搜索范围内找不到触发时间
+	for _, expr := range []string{"0 0 30 2 *", "0 0 31 4 *"} {
+		schedule, err := ParseCron(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if next, ok := schedule.Next(utc(2022, 1, 1, 0, 0, 0)); ok {
+			t.Fatalf("%s: fired at %s", expr, next)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"@fortnightly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"abc * * * *",
+		"* * ,1 * *",
+		"* * L-31 * *",
+		"* * 32W * *",
+		"* * * * 1#6",
+		"* * * * 8L",
+		"* * * FOO *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Fatalf("%q: expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// jobJSON 用于 Job 的 JSON 编解码，时长字段使用秒数(可带小数，如 0.25)或时长字符串(如 "250ms")
+type jobJSON struct {
+	*jobAlias
+	Interval         json.RawMessage `json:"interval,omitempty"`
+	MisfireGraceTime json.RawMessage `json:"misfireGraceTime,omitempty"`
+	Jitter           json.RawMessage `json:"jitter,omitempty"`
+	Timeout          json.RawMessage `json:"timeout,omitempty"`
+}
+
+type jobAlias Job
+
+func (job *Job) UnmarshalJSON(data []byte) error {
+	aux := jobJSON{jobAlias: (*jobAlias)(job)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	var err error
+	if job.Interval, err = parseSeconds("interval", aux.Interval); err != nil {
+		return err
+	}
+	if job.MisfireGraceTime, err = parseSeconds("misfireGraceTime", aux.MisfireGraceTime); err != nil {
+		return err
+	}
+	if job.Jitter, err = parseSeconds("jitter", aux.Jitter); err != nil {
+		return err
+	}
+	if job.Timeout, err = parseSeconds("timeout", aux.Timeout); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (job Job) MarshalJSON() ([]byte, error) {
+	aux := jobJSON{jobAlias: (*jobAlias)(&job)}
+	aux.Interval = formatSeconds(job.Interval)
+	aux.MisfireGraceTime = formatSeconds(job.MisfireGraceTime)
+	aux.Jitter = formatSeconds(job.Jitter)
+	aux.Timeout = formatSeconds(job.Timeout)
+	return json.Marshal(aux)
+}
+
+// parseSeconds 解析时长: 数字表示秒数，字符串按 time.ParseDuration 解析
+func parseSeconds(name string, raw json.RawMessage) (time.Duration, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("invalid %s: %s", name, err.Error()))
+		}
+		return d, nil
+	}
+	return 0, errors.New(fmt.Sprintf("invalid %s: must be a number of seconds or a duration string", name))
+}
+
+func formatSeconds(d time.Duration) json.RawMessage {
+	if d == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(d.Seconds())
+	return b
+}
@@ -0,0 +1,24 @@
+package jobs
+
+// 任务运行实例数达到 MaxInstances 时新一次执行的处理方式
+const (
+	// InstanceSkip 跳过本次执行并记录事件(默认)
+	InstanceSkip = "skip"
+	// InstanceQueue 排队等待正在运行的实例结束后执行
+	InstanceQueue = "queue"
+)
+
+// DefaultMaxInstances 未设置 MaxInstances 时同一任务同时运行的最大实例数
+const DefaultMaxInstances = 1
+
+func validInstancePolicy(policy string) bool {
+	return policy == "" || policy == InstanceSkip || policy == InstanceQueue
+}
+
+// InstanceLimit 返回任务同时运行的最大实例数
+func (job *Job) InstanceLimit() int {
+	if job.MaxInstances <= 0 {
+		return DefaultMaxInstances
+	}
+	return job.MaxInstances
+}
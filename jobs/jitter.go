@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// ScheduledTime 返回触发器计算出的下次触发时间(未加随机抖动)
+func (job *Job) ScheduledTime() time.Time {
+	// 兼容未保存 NextFireTime_ 的旧任务
+	if job.NextFireTime_.IsZero() {
+		return job.NextRunTime_
+	}
+	return job.NextFireTime_
+}
+
+// SetScheduledTime 设置下次触发时间，实际执行时间在 ApplyJitter 之前与其相同
+func (job *Job) SetScheduledTime(t time.Time) {
+	job.NextFireTime_ = t
+	job.NextRunTime_ = t
+}
+
+// ApplyJitter 在触发时间上增加 [0, Jitter) 的随机延迟作为实际执行时间 NextRunTime_，
+// 触发时间本身不变，后续触发时间仍按原计划计算，不会漂移
+func (job *Job) ApplyJitter() {
+	job.NextRunTime_ = job.ScheduledTime()
+	if job.Jitter <= 0 || job.NextRunTime() == 0 {
+		return
+	}
+	jitterMu.Lock()
+	offset := time.Duration(jitterRand.Int63n(int64(job.Jitter)))
+	jitterMu.Unlock()
+	job.NextRunTime_ = job.NextRunTime_.Add(offset)
+}
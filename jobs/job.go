@@ -3,6 +3,8 @@ package jobs
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"time"
 )
@@ -10,50 +12,241 @@ import (
 const (
 	ExecutionOnce = 1 << iota
 	ExecutionPeriodic
+	ExecutionCron
+	ExecutionRRule
 )
 
 const (
 	ParseTimeLayout = "2006-01-02 15:04:05"
 )
 
+// 任务状态
+const (
+	StatusScheduled = "scheduled"
+	// StatusFinished 已达到结束时间或最大执行次数、或触发器不再触发，任务保留但不再调度
+	StatusFinished = "finished"
+)
+
 type Job struct {
-	Id           string        `json:"id"`
-	Name         string        `json:"name"`
-	FuncName     string        `json:"funcName"`
-	Args         []interface{} `json:"args"`
-	StartTime    time.Time     `json:"startTime"`
+	Id       string        `json:"id"`
+	Name     string        `json:"name"`
+	FuncName string        `json:"funcName"`
+	Args     []interface{} `json:"args"`
+	// Kwargs 按字段名填充函数最后一个结构体参数，见 executors.Register
+	Kwargs       map[string]interface{} `json:"kwargs,omitempty"`
+	StartTime    time.Time              `json:"startTime"`
 	NextRunTime_ time.Time
 	Interval     time.Duration `json:"interval"`
+	Cron         string        `json:"cron"`
+	RRule        string        `json:"rrule"`
+	Timezone     string        `json:"timezone"`
 	Type         uint8         `json:"type"`
+	Trigger      *TriggerSpec  `json:"trigger,omitempty"`
+	// Calendar 日历名称，CalendarPolicy 为 skip(默认) 或 shift
+	Calendar       string `json:"calendar"`
+	CalendarPolicy string `json:"calendarPolicy"`
+	// EndTime 结束时间，晚于该时间不再执行；MaxRuns 最大执行次数，0 表示不限
+	EndTime time.Time `json:"endTime"`
+	MaxRuns int       `json:"maxRuns"`
+	Runs    int       `json:"runs"`
+	Status  string    `json:"status"`
+	// MisfireGraceTime 允许的最大延迟秒数，MisfirePolicy 超过后的处理策略，见 misfire.go
+	MisfireGraceTime time.Duration `json:"misfireGraceTime"`
+	MisfirePolicy    string        `json:"misfirePolicy"`
+	// MaxInstances 同时运行的最大实例数，InstancePolicy 达到上限时的处理方式，见 instances.go
+	// Coalesce 将错过或排队的多次执行合并为一次
+	MaxInstances   int    `json:"maxInstances"`
+	InstancePolicy string `json:"instancePolicy"`
+	Coalesce       bool   `json:"coalesce"`
+	// Jitter 随机抖动秒数，每次实际执行时间在触发时间后 [0, Jitter) 内随机
+	Jitter time.Duration `json:"jitter"`
+	// NextFireTime_ 触发器计算出的下次触发时间，NextRunTime_ 为加上随机抖动后的实际执行时间
+	NextFireTime_ time.Time
+	// WorkflowRunId、WorkflowNode 由工作流触发的执行所属的运行及节点，见 workflow.go
+	WorkflowRunId string `json:"workflowRunId,omitempty"`
+	WorkflowNode  string `json:"workflowNode,omitempty"`
+	// OnSuccess 执行成功后执行的后续任务，ResultArgs 为后续任务参数与上一个任务返回值的对应关系，见 chain.go
+	OnSuccess  *Job        `json:"onSuccess,omitempty"`
+	ResultArgs []ResultArg `json:"resultArgs,omitempty"`
+	// Retry 执行失败后的重试策略，见 retry.go；Attempt 为重试任务已失败的执行次数，
+	// RetryOf 为原任务 id(重试的任务，或工作流节点引用的任务)，实例数及取消均按原任务计
+	Retry   *RetryPolicy `json:"retry,omitempty"`
+	Attempt int          `json:"attempt,omitempty"`
+	RetryOf string       `json:"retryOf,omitempty"`
+	// Timeout 单次执行的超时秒数，0 表示不限；函数第一个参数为 context.Context 时可感知取消
+	Timeout time.Duration `json:"timeout"`
+	// Kind 任务类型，默认调用注册的函数 FuncName，shell 类型执行 Shell 定义的命令，
+	// http 类型发送 HTTP 定义的请求，grpc 类型调用 GRPC 定义的方法，见 kind.go
+	Kind  string     `json:"kind,omitempty"`
+	Shell *ShellSpec `json:"shell,omitempty"`
+	HTTP  *HTTPSpec  `json:"http,omitempty"`
+	GRPC  *GRPCSpec  `json:"grpc,omitempty"`
+	// Queue 远程执行时任务所在的队列，默认 DefaultQueue；Labels 执行任务的 worker 需具有的标签，见 routing.go
+	Queue  string            `json:"queue,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // New returns a valid job
 // @param name : job name,
 // @param funcName: 要执行的函数名称
-// @param startTime: 任务开始时间, web传递格式为 2022-06-03T18:02:03Z
+// @param startTime: 任务开始时间, web传递格式为 2022-06-03T18:02:03Z, 按任务时区(Timezone, 默认服务器本地时区)的挂钟时间解释
 // @param interval: 周期性任务执行时间间隔，秒
-// @param jobType: 1 一次性任务，2 周期性任务
+// @param jobType: 1 一次性任务，2 周期性任务，4 cron 任务，8 RRULE 任务(需另外设置 Cron / RRule 后调用 Init)，设置了 Trigger 时忽略
 // @param args: 要执行函数的参数
 func New(name, funcName string, startTime time.Time, interval time.Duration, jobType uint8, args ...interface{}) *Job {
-	id := uuid.New().String()
-	_startTime, _ := time.ParseInLocation(ParseTimeLayout, startTime.Format(ParseTimeLayout), time.Local)
-	return &Job{
-		Id:           id,
-		Name:         name,
-		FuncName:     funcName,
-		Args:         args,
-		StartTime:    _startTime,
-		NextRunTime_: _startTime,
-		Interval:     interval * time.Second,
-		Type:         jobType,
+	job := &Job{
+		Name:      name,
+		FuncName:  funcName,
+		Args:      args,
+		StartTime: startTime,
+		Interval:  interval * time.Second,
+		Type:      jobType,
+	}
+	_ = job.Init()
+	return job
+}
+
+// Init 为新提交的任务生成 id，并计算首次执行时间
+func (job *Job) Init() error {
+	job.Id = uuid.New().String()
+	loc, err := LoadLocation(job.Timezone)
+	if err != nil {
+		return err
+	}
+	// 开始时间按任务时区的挂钟时间解释，夏令时切换时的处理见 ResolveWallClock
+	job.StartTime = ResolveWallClock(job.StartTime, loc)
+	if !job.EndTime.IsZero() {
+		job.EndTime = ResolveWallClock(job.EndTime, loc)
+		if !job.EndTime.After(job.StartTime) {
+			return errors.New("end time must be later than start time")
+		}
+	}
+	if job.MaxRuns < 0 {
+		return errors.New("max runs must not be negative")
+	}
+	job.Runs = 0
+	job.Status = StatusScheduled
+	if !validMisfirePolicy(job.MisfirePolicy) {
+		return errors.New(fmt.Sprintf("invalid misfire policy %q", job.MisfirePolicy))
+	}
+	if job.MaxInstances < 0 {
+		return errors.New("max instances must not be negative")
+	}
+	if !validInstancePolicy(job.InstancePolicy) {
+		return errors.New(fmt.Sprintf("invalid instance policy %q", job.InstancePolicy))
+	}
+	if job.CalendarPolicy != "" && job.CalendarPolicy != CalendarSkip && job.CalendarPolicy != CalendarShift {
+		return errors.New(fmt.Sprintf("invalid calendar policy %q", job.CalendarPolicy))
+	}
+	if err = job.validateChain(); err != nil {
+		return err
+	}
+	if job.Retry != nil {
+		if err = job.Retry.Validate(); err != nil {
+			return err
+		}
+	}
+	if job.Timeout < 0 {
+		return errors.New("timeout must not be negative")
+	}
+	if err = job.validateKind(); err != nil {
+		return err
+	}
+	if err = job.validateRouting(); err != nil {
+		return err
+	}
+	trigger, err := job.NewTrigger()
+	if err != nil {
+		return err
+	}
+	next, ok := trigger.NextFireTime(time.Time{}, time.Now())
+	if !ok {
+		return errors.New(fmt.Sprintf("trigger %q never fires", job.TriggerSpec().Type))
+	}
+	job.SetScheduledTime(next)
+	if job.ReachedEnd() {
+		return errors.New("job never runs before its end time")
+	}
+	return nil
+}
+
+// ReachedEnd 判断任务是否已达到最大执行次数，或下次执行时间已晚于结束时间
+func (job *Job) ReachedEnd() bool {
+	if job.MaxRuns > 0 && job.Runs >= job.MaxRuns {
+		return true
+	}
+	return !job.EndTime.IsZero() && job.ScheduledTime().After(job.EndTime)
+}
+
+// Finish 将任务标记为已结束，任务仍保留在 store 中但不再调度
+func (job *Job) Finish() {
+	job.Status = StatusFinished
+	job.SetScheduledTime(time.Unix(0, 0))
+}
+
+// Finished 判断任务是否已结束
+func (job *Job) Finished() bool {
+	return job.Status == StatusFinished
+}
+
+// Location 返回任务所在时区，时区无效时使用服务器本地时区
+func (job *Job) Location() *time.Location {
+	loc, err := LoadLocation(job.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// TriggerSpec 返回任务的触发器定义，未设置 Trigger 时按任务类型选择内置触发器
+func (job *Job) TriggerSpec() TriggerSpec {
+	if job.Trigger != nil {
+		return *job.Trigger
 	}
+	switch job.Type {
+	case ExecutionPeriodic:
+		return TriggerSpec{Type: TriggerInterval}
+	case ExecutionCron:
+		return TriggerSpec{Type: TriggerCron}
+	case ExecutionRRule:
+		return TriggerSpec{Type: TriggerRRule}
+	}
+	return TriggerSpec{Type: TriggerDate}
+}
+
+// NewTrigger 创建任务的触发器
+func (job *Job) NewTrigger() (Trigger, error) {
+	return NewTrigger(job, job.TriggerSpec())
 }
 
+// NextRunTime 返回下次执行时间的毫秒时间戳，作为 store 中的排序分值
 func (job *Job) NextRunTime() float64 {
-	t := job.NextRunTime_.Unix()
+	t := job.NextRunTime_.UnixNano() / int64(time.Millisecond)
 	return float64(t)
 }
 
+// ModifiesSchedule 判断修改内容是否影响任务的执行时间
+func (job *Job) ModifiesSchedule() bool {
+	return job.Interval != 0 || job.Cron != "" || job.RRule != "" || job.Timezone != "" ||
+		job.Trigger != nil || job.Calendar != "" || job.CalendarPolicy != "" ||
+		!job.EndTime.IsZero() || job.Jitter > 0
+}
+
+// Reschedule 从 now 起重新计算任务的下次触发时间，不再触发时标记为已结束
+func (job *Job) Reschedule(now time.Time) error {
+	trigger, err := job.NewTrigger()
+	if err != nil {
+		return err
+	}
+	next, ok := trigger.NextFireTime(now, now)
+	if !ok {
+		job.Finish()
+		return nil
+	}
+	job.SetScheduledTime(next)
+	return nil
+}
+
 func (job *Job) Update(modified Job) error {
 	if modified.Name != "" {
 		job.Name = modified.Name
@@ -61,6 +254,111 @@ func (job *Job) Update(modified Job) error {
 	if modified.Interval != 0 {
 		job.Interval = modified.Interval
 	}
+	if modified.Cron != "" {
+		if _, err := ParseCron(modified.Cron); err != nil {
+			return err
+		}
+		job.Cron = modified.Cron
+	}
+	if modified.RRule != "" {
+		if _, err := ParseRecurrence(modified.RRule, job.StartTime, job.Location()); err != nil {
+			return err
+		}
+		job.RRule = modified.RRule
+	}
+	if modified.Timezone != "" {
+		if _, err := LoadLocation(modified.Timezone); err != nil {
+			return err
+		}
+		job.Timezone = modified.Timezone
+	}
+	if !modified.EndTime.IsZero() {
+		job.EndTime = ResolveWallClock(modified.EndTime, job.Location())
+	}
+	if modified.MaxRuns > 0 {
+		job.MaxRuns = modified.MaxRuns
+	}
+	if modified.MisfireGraceTime != 0 {
+		job.MisfireGraceTime = modified.MisfireGraceTime
+	}
+	if modified.MisfirePolicy != "" {
+		if !validMisfirePolicy(modified.MisfirePolicy) {
+			return errors.New(fmt.Sprintf("invalid misfire policy %q", modified.MisfirePolicy))
+		}
+		job.MisfirePolicy = modified.MisfirePolicy
+	}
+	if modified.MaxInstances > 0 {
+		job.MaxInstances = modified.MaxInstances
+	}
+	if modified.InstancePolicy != "" {
+		if !validInstancePolicy(modified.InstancePolicy) {
+			return errors.New(fmt.Sprintf("invalid instance policy %q", modified.InstancePolicy))
+		}
+		job.InstancePolicy = modified.InstancePolicy
+	}
+	if modified.Jitter > 0 {
+		job.Jitter = modified.Jitter
+	}
+	if modified.Calendar != "" {
+		job.Calendar = modified.Calendar
+	}
+	if modified.CalendarPolicy != "" {
+		if modified.CalendarPolicy != CalendarSkip && modified.CalendarPolicy != CalendarShift {
+			return errors.New(fmt.Sprintf("invalid calendar policy %q", modified.CalendarPolicy))
+		}
+		job.CalendarPolicy = modified.CalendarPolicy
+	}
+	if modified.Trigger != nil {
+		if _, err := NewTrigger(job, *modified.Trigger); err != nil {
+			return err
+		}
+		job.Trigger = modified.Trigger
+	}
+	if modified.OnSuccess != nil {
+		if err := modified.validateChain(); err != nil {
+			return err
+		}
+		job.OnSuccess = modified.OnSuccess
+	}
+	if modified.Retry != nil {
+		if err := modified.Retry.Validate(); err != nil {
+			return err
+		}
+		job.Retry = modified.Retry
+	}
+	if modified.Timeout > 0 {
+		job.Timeout = modified.Timeout
+	}
+	if modified.Shell != nil {
+		if err := modified.Shell.Validate(); err != nil {
+			return err
+		}
+		job.Shell = modified.Shell
+	}
+	if modified.HTTP != nil {
+		if err := modified.HTTP.Validate(); err != nil {
+			return err
+		}
+		job.HTTP = modified.HTTP
+	}
+	if modified.GRPC != nil {
+		if err := modified.GRPC.Validate(); err != nil {
+			return err
+		}
+		job.GRPC = modified.GRPC
+	}
+	if modified.Queue != "" || modified.Labels != nil {
+		routing := Job{Queue: modified.Queue, Labels: modified.Labels}
+		if err := routing.validateRouting(); err != nil {
+			return err
+		}
+		if modified.Queue != "" {
+			job.Queue = modified.Queue
+		}
+		if modified.Labels != nil {
+			job.Labels = modified.Labels
+		}
+	}
 	return nil
 }
 
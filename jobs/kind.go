@@ -0,0 +1,210 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// 任务类型，决定执行器如何执行任务
+const (
+	// KindFunc 调用注册的 Go 函数(默认)
+	KindFunc = "func"
+	// KindShell 执行 shell 命令，见 ShellSpec
+	KindShell = "shell"
+	// KindHTTP 发送 http 请求，见 HTTPSpec
+	KindHTTP = "http"
+	// KindGRPC 调用 gRPC 一元方法，见 GRPCSpec
+	KindGRPC = "grpc"
+)
+
+// ShellSpec shell 类型任务的命令定义，命令直接执行，不经过 shell 解释
+type ShellSpec struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Dir 工作目录，默认为调度器的工作目录
+	Dir string `json:"dir"`
+	// Env 在调度器环境变量基础上追加或覆盖的环境变量
+	Env map[string]string `json:"env"`
+	// SuccessCodes 视为执行成功的退出码，默认为 0
+	SuccessCodes []int `json:"successCodes"`
+}
+
+// Validate 校验 shell 命令定义
+func (spec *ShellSpec) Validate() error {
+	if strings.TrimSpace(spec.Command) == "" {
+		return errors.New("shell command must not be empty")
+	}
+	for name := range spec.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return errors.New(fmt.Sprintf("invalid shell env name %q", name))
+		}
+	}
+	return nil
+}
+
+// Succeeded 判断退出码是否视为执行成功
+func (spec *ShellSpec) Succeeded(exitCode int) bool {
+	if len(spec.SuccessCodes) == 0 {
+		return exitCode == 0
+	}
+	for _, code := range spec.SuccessCodes {
+		if code == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPSpec http 类型任务的请求定义，请求超时使用任务的 Timeout
+type HTTPSpec struct {
+	// Method 默认 GET
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Body 请求体模板(text/template)，可引用 .Job、.Now、.ScheduledTime
+	Body string `json:"body"`
+	// ExpectedStatus 视为执行成功的状态码，默认 2xx
+	ExpectedStatus []int `json:"expectedStatus"`
+	// RetryStatus 按任务的重试策略重试的状态码，设置后其他非期望状态码不再重试
+	RetryStatus []int    `json:"retryStatus"`
+	TLS         *TLSSpec `json:"tls,omitempty"`
+}
+
+// TLSSpec https 请求的 TLS 选项
+type TLSSpec struct {
+	// CAFile 校验服务端证书的 CA 证书文件，默认使用系统 CA
+	CAFile string `json:"caFile"`
+	// CertFile、KeyFile 客户端证书及私钥文件
+	CertFile   string `json:"certFile"`
+	KeyFile    string `json:"keyFile"`
+	ServerName string `json:"serverName"`
+	// InsecureSkipVerify 不校验服务端证书，仅用于测试
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// Validate 校验 http 请求定义
+func (spec *HTTPSpec) Validate() error {
+	u, err := url.Parse(spec.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New(fmt.Sprintf("invalid http url %q", spec.URL))
+	}
+	if spec.Method != "" && strings.ContainsAny(spec.Method, " \t\r\n") {
+		return errors.New(fmt.Sprintf("invalid http method %q", spec.Method))
+	}
+	if _, err = template.New("body").Parse(spec.Body); err != nil {
+		return errors.New(fmt.Sprintf("invalid http body template: %s", err.Error()))
+	}
+	for _, code := range append(append([]int{}, spec.ExpectedStatus...), spec.RetryStatus...) {
+		if code < 100 || code > 599 {
+			return errors.New(fmt.Sprintf("invalid http status code %d", code))
+		}
+	}
+	if spec.TLS != nil {
+		return spec.TLS.Validate()
+	}
+	return nil
+}
+
+// Validate 校验 TLS 选项
+func (spec *TLSSpec) Validate() error {
+	if (spec.CertFile == "") != (spec.KeyFile == "") {
+		return errors.New("tls certFile and keyFile must be set together")
+	}
+	return nil
+}
+
+// Expected 判断状态码是否视为执行成功
+func (spec *HTTPSpec) Expected(status int) bool {
+	if len(spec.ExpectedStatus) == 0 {
+		return status >= 200 && status < 300
+	}
+	return containsInt(spec.ExpectedStatus, status)
+}
+
+// ShouldRetry 判断非期望的状态码是否应重试，未设置 RetryStatus 时返回 false, false
+func (spec *HTTPSpec) ShouldRetry(status int) (retry bool, decided bool) {
+	if len(spec.RetryStatus) == 0 {
+		return false, false
+	}
+	return containsInt(spec.RetryStatus, status), true
+}
+
+// GRPCSpec grpc 类型任务的调用定义，调用的 deadline 为任务的 Timeout
+type GRPCSpec struct {
+	// Target 服务地址，如 localhost:50051、dns:///orders.internal:443
+	Target string `json:"target"`
+	// Method 完整方法名，如 orders.v1.OrderService/Expire
+	Method string `json:"method"`
+	// Request JSON 格式的请求消息，按 protobuf 的 JSON 映射转换
+	Request json.RawMessage `json:"request"`
+	// Metadata 调用时附带的 metadata
+	Metadata map[string]string `json:"metadata"`
+	// DescriptorSet 包含服务定义的 FileDescriptorSet 文件(protoc --descriptor_set_out --include_imports)，
+	// 未设置时通过服务端反射获取
+	DescriptorSet string `json:"descriptorSet"`
+	// TLS 未设置时使用明文连接
+	TLS *TLSSpec `json:"tls,omitempty"`
+}
+
+// Validate 校验 grpc 调用定义
+func (spec *GRPCSpec) Validate() error {
+	if strings.TrimSpace(spec.Target) == "" {
+		return errors.New("grpc target must not be empty")
+	}
+	if _, _, err := spec.ServiceMethod(); err != nil {
+		return err
+	}
+	if len(spec.Request) > 0 && !json.Valid(spec.Request) {
+		return errors.New("grpc request must be valid json")
+	}
+	if spec.TLS != nil {
+		return spec.TLS.Validate()
+	}
+	return nil
+}
+
+// ServiceMethod 返回方法所属的服务全名及方法名
+func (spec *GRPCSpec) ServiceMethod() (service string, method string, err error) {
+	name := strings.TrimPrefix(spec.Method, "/")
+	i := strings.LastIndex(name, "/")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", errors.New(fmt.Sprintf("invalid grpc method %q, expected package.Service/Method", spec.Method))
+	}
+	return name[:i], name[i+1:], nil
+}
+
+// KindOf 返回任务类型，未设置时为 KindFunc
+func (job *Job) KindOf() string {
+	if job.Kind == "" {
+		return KindFunc
+	}
+	return job.Kind
+}
+
+// validateKind 校验任务类型及对应的定义
+func (job *Job) validateKind() error {
+	switch job.KindOf() {
+	case KindFunc:
+		return nil
+	case KindShell:
+		if job.Shell == nil {
+			return errors.New("shell job requires a shell spec")
+		}
+		return job.Shell.Validate()
+	case KindHTTP:
+		if job.HTTP == nil {
+			return errors.New("http job requires an http spec")
+		}
+		return job.HTTP.Validate()
+	case KindGRPC:
+		if job.GRPC == nil {
+			return errors.New("grpc job requires a grpc spec")
+		}
+		return job.GRPC.Validate()
+	}
+	return errors.New(fmt.Sprintf("invalid job kind %q", job.Kind))
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import "time"
+
+// 错过执行(调度器停机或阻塞导致延迟超过 MisfireGraceTime)时的处理策略
+const (
+	// MisfireFireOnceNow 立即执行一次，之后从当前时间起按触发器继续调度(默认)
+	MisfireFireOnceNow = "fire-once-now"
+	// MisfireFireAllMissed 立即补执行所有错过的执行(最多 MaxMisfireRuns 次)
+	MisfireFireAllMissed = "fire-all-missed"
+	// MisfireSkipToNext 跳过错过的执行，等待下一次执行时间
+	MisfireSkipToNext = "skip-to-next"
+)
+
+const (
+	// DefaultMisfireGraceTime 未设置 MisfireGraceTime 时允许的最大延迟
+	DefaultMisfireGraceTime = time.Second
+	// MaxMisfireRuns fire-all-missed 策略一次最多补执行的次数
+	MaxMisfireRuns = 1000
+)
+
+func validMisfirePolicy(policy string) bool {
+	switch policy {
+	case "", MisfireFireOnceNow, MisfireFireAllMissed, MisfireSkipToNext:
+		return true
+	}
+	return false
+}
+
+// MisfireGrace 返回任务允许的最大延迟
+func (job *Job) MisfireGrace() time.Duration {
+	if job.MisfireGraceTime <= 0 {
+		return DefaultMisfireGraceTime
+	}
+	return job.MisfireGraceTime
+}
+
+// Misfired 判断任务在 now 时是否已错过执行
+func (job *Job) Misfired(now time.Time) bool {
+	return now.Sub(job.NextRunTime_) > job.MisfireGrace()
+}
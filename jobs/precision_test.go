@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// tickInterval 与 schedulers.TickInterval 相同，调度器每隔该时间按毫秒分值取出到期任务
+const tickInterval = 10 * time.Millisecond
+
+// due 与 RedisJobStore.GetJobs2Run 相同：分值不大于当前毫秒时间戳的任务到期
+func due(job *Job, now time.Time) bool {
+	return job.NextRunTime() != 0 && job.NextRunTime() <= float64(now.UnixNano()/int64(time.Millisecond))
+}
+
+// fire 模拟调度器从 start 起按 tickInterval 检查，返回任务到期时的时间
+func fire(t *testing.T, job *Job, start time.Time) time.Time {
+	for now := start; now.Before(start.Add(time.Hour)); now = now.Add(tickInterval) {
+		if due(job, now) {
+			return now
+		}
+	}
+	t.Fatalf("job scheduled at %s never became due", job.NextRunTime_.Format(time.RFC3339Nano))
+	return time.Time{}
+}
+
+func newTestJob(t *testing.T, data string) *Job {
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		t.Fatal(err)
+	}
+	if err := job.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func TestIntervalMilliseconds(t *testing.T) {
+	for _, interval := range []string{`0.25`, `"250ms"`} {
+		job := newTestJob(t, `{"funcName": "add", "startTime": "2022-06-04T12:00:00Z", "interval": `+interval+`, "type": 2}`)
+		if job.Interval != 250*time.Millisecond {
+			t.Fatalf("interval %s parsed as %s", interval, job.Interval)
+		}
+		trigger, err := job.NewTrigger()
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := job.ScheduledTime()
+		// 调度器晚于触发时间计算下次执行时间，触发时间仍对齐到 startTime + n * 250ms，不会累积漂移
+		for n := 1; n <= 10000; n++ {
+			late := job.ScheduledTime().Add(7 * time.Millisecond)
+			next, ok := trigger.NextFireTime(job.ScheduledTime(), late)
+			if !ok {
+				t.Fatal("interval trigger stopped firing")
+			}
+			if want := start.Add(time.Duration(n) * 250 * time.Millisecond); !next.Equal(want) {
+				t.Fatalf("run %d scheduled at %s, want %s", n, next.Format(time.RFC3339Nano), want.Format(time.RFC3339Nano))
+			}
+			job.SetScheduledTime(next)
+		}
+	}
+}
+
+func TestStoreScoreMilliseconds(t *testing.T) {
+	job := newTestJob(t, `{"funcName": "add", "startTime": "2022-06-04T12:00:00.500Z", "type": 1}`)
+	at := time.Date(2022, 6, 4, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	if want := float64(at.Unix()*1000 + 500); job.NextRunTime() != want {
+		t.Fatalf("score %f, want %f", job.NextRunTime(), want)
+	}
+
+	// store 中保存的 JSON 需保留毫秒，重新加载后分值不变
+	data, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Job
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NextRunTime() != job.NextRunTime() {
+		t.Fatalf("score after reload %f, want %f", loaded.NextRunTime(), job.NextRunTime())
+	}
+
+	if due(job, at.Add(-time.Millisecond)) {
+		t.Fatal("job due 1ms before its fire time")
+	}
+	if !due(job, at) {
+		t.Fatal("job not due at its fire time")
+	}
+}
+
+func TestFireTimeMilliseconds(t *testing.T) {
+	job := newTestJob(t, `{"funcName": "add", "startTime": "2022-06-04T12:00:00.500Z", "type": 1}`)
+	at := time.Date(2022, 6, 4, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	// 调度器的检查时间与触发时间不对齐时，也在下一次检查时执行
+	for _, offset := range []time.Duration{0, time.Millisecond, 3 * time.Millisecond, 9 * time.Millisecond} {
+		fired := fire(t, job, time.Date(2022, 6, 4, 12, 0, 0, 0, time.UTC).Add(offset))
+		if drift := fired.Sub(at); drift < 0 || drift >= tickInterval {
+			t.Fatalf("offset %s: fired at %s, drift %s", offset, fired.Format(time.RFC3339Nano), drift)
+		}
+		if job.Misfired(fired) {
+			t.Fatalf("offset %s: job misfired at %s", offset, fired.Format(time.RFC3339Nano))
+		}
+	}
+}
+
+func TestIntervalDriftBound(t *testing.T) {
+	job := newTestJob(t, `{"funcName": "add", "startTime": "2022-06-04T12:00:00.500Z", "interval": "250ms", "type": 2}`)
+	trigger, err := job.NewTrigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := job.ScheduledTime()
+	now := time.Date(2022, 6, 4, 12, 0, 0, 3*int(time.Millisecond), time.UTC)
+	// 每次执行的延迟都不超过一个检查间隔，且不随执行次数增加
+	for n := 0; n < 1000; n++ {
+		now = fire(t, job, now)
+		want := start.Add(time.Duration(n) * 250 * time.Millisecond)
+		if drift := now.Sub(want); drift < 0 || drift >= tickInterval {
+			t.Fatalf("run %d fired at %s, drift %s", n, now.Format(time.RFC3339Nano), drift)
+		}
+		next, _ := trigger.NextFireTime(job.ScheduledTime(), now)
+		job.SetScheduledTime(next)
+	}
+}
@@ -0,0 +1,237 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"strings"
+	"time"
+)
+
+// 重试策略的默认值
+const (
+	DefaultRetryInitialDelay = time.Second
+	DefaultRetryMultiplier   = 2.0
+)
+
+// RetryPolicy 任务执行失败后的重试策略，第 n 次重试在失败后
+// min(InitialDelay * Multiplier^(n-1), MaxDelay) 加上 [0, Jitter) 的随机延迟执行
+type RetryPolicy struct {
+	// MaxAttempts 最大执行次数(含首次执行)，小于2时不重试
+	MaxAttempts  int           `json:"maxAttempts"`
+	InitialDelay time.Duration `json:"initialDelay"`
+	Multiplier   float64       `json:"multiplier"`
+	// MaxDelay 最大重试间隔，0 表示不限
+	MaxDelay time.Duration `json:"maxDelay"`
+	Jitter   time.Duration `json:"jitter"`
+	// RetryOn 可重试的错误，错误信息包含其中任一字符串时重试；为空时所有错误均重试
+	RetryOn []string `json:"retryOn"`
+}
+
+// retryPolicyJSON 时长字段与 Job 一致，使用秒数或时长字符串
+type retryPolicyJSON struct {
+	*retryPolicyAlias
+	InitialDelay json.RawMessage `json:"initialDelay,omitempty"`
+	MaxDelay     json.RawMessage `json:"maxDelay,omitempty"`
+	Jitter       json.RawMessage `json:"jitter,omitempty"`
+}
+
+type retryPolicyAlias RetryPolicy
+
+func (policy *RetryPolicy) UnmarshalJSON(data []byte) error {
+	aux := retryPolicyJSON{retryPolicyAlias: (*retryPolicyAlias)(policy)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	var err error
+	if policy.InitialDelay, err = parseSeconds("initialDelay", aux.InitialDelay); err != nil {
+		return err
+	}
+	if policy.MaxDelay, err = parseSeconds("maxDelay", aux.MaxDelay); err != nil {
+		return err
+	}
+	if policy.Jitter, err = parseSeconds("jitter", aux.Jitter); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (policy RetryPolicy) MarshalJSON() ([]byte, error) {
+	aux := retryPolicyJSON{retryPolicyAlias: (*retryPolicyAlias)(&policy)}
+	aux.InitialDelay = formatSeconds(policy.InitialDelay)
+	aux.MaxDelay = formatSeconds(policy.MaxDelay)
+	aux.Jitter = formatSeconds(policy.Jitter)
+	return json.Marshal(aux)
+}
+
+// Validate 校验重试策略
+func (policy *RetryPolicy) Validate() error {
+	if policy.MaxAttempts < 0 {
+		return errors.New("retry max attempts must not be negative")
+	}
+	if policy.InitialDelay < 0 || policy.MaxDelay < 0 || policy.Jitter < 0 {
+		return errors.New("retry delays must not be negative")
+	}
+	if policy.Multiplier != 0 && policy.Multiplier < 1 {
+		return errors.New("retry multiplier must not be less than 1")
+	}
+	return nil
+}
+
+// Retryable 判断第 attempt 次执行(从1开始)失败后是否应重试
+func (policy *RetryPolicy) Retryable(attempt int, err error) bool {
+	if err == nil || attempt >= policy.MaxAttempts {
+		return false
+	}
+	var decided *RetryableError
+	if errors.As(err, &decided) {
+		return decided.Retry
+	}
+	if len(policy.RetryOn) == 0 {
+		return true
+	}
+	for _, s := range policy.RetryOn {
+		if strings.Contains(err.Error(), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryableError 由执行方决定是否重试的错误，优先于 RetryOn，如 http 任务按 RetryStatus 判断响应状态码
+type RetryableError struct {
+	Err   error
+	Retry bool
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// Delay 返回第 attempt 次执行(从1开始)失败后到下次重试的间隔
+func (policy *RetryPolicy) Delay(attempt int) time.Duration {
+	initial := policy.InitialDelay
+	if initial <= 0 {
+		initial = DefaultRetryInitialDelay
+	}
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if policy.MaxDelay > 0 && delay > float64(policy.MaxDelay) {
+		delay = float64(policy.MaxDelay)
+	}
+	// 避免溢出
+	if delay > math.MaxInt64/2 {
+		delay = math.MaxInt64 / 2
+	}
+	d := time.Duration(delay)
+	if policy.Jitter > 0 {
+		jitterMu.Lock()
+		d += time.Duration(jitterRand.Int63n(int64(policy.Jitter)))
+		jitterMu.Unlock()
+	}
+	return d
+}
+
+// CurrentAttempt 返回本次执行是第几次执行(从1开始)
+func (job *Job) CurrentAttempt() int {
+	return job.Attempt + 1
+}
+
+// OriginalId 返回重试任务对应的原任务 id
+func (job *Job) OriginalId() string {
+	if job.RetryOf != "" {
+		return job.RetryOf
+	}
+	return job.Id
+}
+
+// NewRetry 创建在 at 时刻执行一次的重试任务，保留原任务的函数、参数、重试策略及后续任务
+func (job *Job) NewRetry(at time.Time) Job {
+	retry := Job{
+		Name:           job.Name,
+		FuncName:       job.FuncName,
+		Args:           job.Args,
+		Kwargs:         job.Kwargs,
+		StartTime:      at,
+		Timezone:       job.Timezone,
+		Type:           ExecutionOnce,
+		Trigger:        &TriggerSpec{Type: TriggerDate},
+		MaxInstances:   job.MaxInstances,
+		InstancePolicy: job.InstancePolicy,
+		Status:         StatusScheduled,
+		Retry:          job.Retry,
+		Attempt:        job.CurrentAttempt(),
+		RetryOf:        job.OriginalId(),
+		OnSuccess:      job.OnSuccess,
+		ResultArgs:     job.ResultArgs,
+		Timeout:        job.Timeout,
+		Kind:           job.Kind,
+		Shell:          job.Shell,
+		HTTP:           job.HTTP,
+		GRPC:           job.GRPC,
+		Queue:          job.Queue,
+		Labels:         job.Labels,
+		// 工作流节点的重试结束后才决定节点的状态
+		WorkflowRunId: job.WorkflowRunId,
+		WorkflowNode:  job.WorkflowNode,
+	}
+	// 周期任务的多次执行可能同时在重试，id 需唯一
+	retry.Id = fmt.Sprintf("%s/retry/%s", retry.RetryOf, uuid.New().String())
+	retry.SetScheduledTime(at)
+	return retry
+}
+
+// IsRetry 判断任务是否为失败后创建的重试任务，引用已有任务的工作流节点也以 RetryOf 记录原任务
+func (job *Job) IsRetry() bool {
+	return job.RetryOf != ""
+}
+
+// OneShot 判断任务是否只执行一次、执行后不再保留在 store 中: 重试任务及工作流节点
+func (job *Job) OneShot() bool {
+	return job.IsRetry() || job.WorkflowRunId != ""
+}
+
+// DeadLetter 失败的执行，有重试策略时为重试次数用尽后仍失败的执行
+type DeadLetter struct {
+	Id       string    `json:"id"`
+	Job      Job       `json:"job"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+}
+
+// NewDeadLetter 记录任务执行失败且不再重试
+func NewDeadLetter(job Job, err error) DeadLetter {
+	return DeadLetter{
+		Id:       uuid.New().String(),
+		Job:      job,
+		Attempts: job.CurrentAttempt(),
+		Error:    err.Error(),
+		Time:     time.Now(),
+	}
+}
+
+func (deadLetter *DeadLetter) Bytes() []byte {
+	// 使用 encoding/gob 序列化
+	buf := new(bytes.Buffer)
+	_ = gob.NewEncoder(buf).Encode(deadLetter)
+	return buf.Bytes()
+}
+
+func BytesToDeadLetter(b []byte) *DeadLetter {
+	// 使用 encoding/gob 反序列化
+	var deadLetter DeadLetter
+	_ = gob.NewDecoder(bytes.NewBuffer(b)).Decode(&deadLetter)
+	return &deadLetter
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRetryKeepsWorkflowNode(t *testing.T) {
+	job := Job{
+		Id:            "run-1/extract",
+		FuncName:      "add",
+		Retry:         &RetryPolicy{MaxAttempts: 3},
+		WorkflowRunId: "run-1",
+		WorkflowNode:  "extract",
+	}
+	retry := job.NewRetry(time.Now())
+	if retry.WorkflowRunId != job.WorkflowRunId || retry.WorkflowNode != job.WorkflowNode {
+		t.Fatalf("retry belongs to run %q node %q", retry.WorkflowRunId, retry.WorkflowNode)
+	}
+	if !strings.HasPrefix(retry.Id, "run-1/extract/retry/") || retry.OriginalId() != job.Id {
+		t.Fatalf("retry id %s, original id %s", retry.Id, retry.OriginalId())
+	}
+
+	// 最后一次重试仍属于该节点，且不再重试
+	last := retry.NewRetry(time.Now())
+	if last.WorkflowNode != job.WorkflowNode || last.CurrentAttempt() != 3 {
+		t.Fatalf("attempt %d of node %q", last.CurrentAttempt(), last.WorkflowNode)
+	}
+	if last.Retry.Retryable(last.CurrentAttempt(), errors.New("failed")) {
+		t.Fatal("retried after max attempts")
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		policy RetryPolicy
+		want   []time.Duration
+	}{
+		// 默认初始间隔 1s，倍数 2
+		{RetryPolicy{}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}},
+		{RetryPolicy{InitialDelay: 3 * time.Second, Multiplier: 1}, []time.Duration{3 * time.Second, 3 * time.Second, 3 * time.Second}},
+		{RetryPolicy{InitialDelay: 100 * time.Millisecond, Multiplier: 3}, []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond}},
+		// 超过 MaxDelay 后保持 MaxDelay
+		{RetryPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 5 * time.Second},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}},
+	}
+	for _, test := range tests {
+		for i, want := range test.want {
+			if got := test.policy.Delay(i + 1); got != want {
+				t.Fatalf("%+v: attempt %d delay %s, want %s", test.policy, i+1, got, want)
+			}
+		}
+	}
+
+	// 次数很大时不溢出
+	policy := RetryPolicy{InitialDelay: time.Second, Multiplier: 10}
+	if got := policy.Delay(1000); got <= 0 {
+		t.Fatalf("attempt 1000 delay %s", got)
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 3 * time.Second, Jitter: 500 * time.Millisecond}
+	for attempt, base := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 5: 3 * time.Second} {
+		jittered := false
+		for i := 0; i < 100; i++ {
+			got := policy.Delay(attempt)
+			// 随机延迟在 [0, Jitter) 内，加在 MaxDelay 限制之后
+			if got < base || got >= base+policy.Jitter {
+				t.Fatalf("attempt %d delay %s, want [%s, %s)", attempt, got, base, base+policy.Jitter)
+			}
+			if got != base {
+				jittered = true
+			}
+		}
+		if !jittered {
+			t.Fatalf("attempt %d delay never jittered", attempt)
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	timeout := errors.New("dial tcp: i/o timeout")
+	refused := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		attempt   int
+		err       error
+		retryable bool
+	}{
+		{"no error", RetryPolicy{MaxAttempts: 3}, 1, nil, false},
+		{"any error", RetryPolicy{MaxAttempts: 3}, 1, refused, true},
+		{"last attempt", RetryPolicy{MaxAttempts: 3}, 3, refused, false},
+		{"no retries", RetryPolicy{MaxAttempts: 1}, 1, refused, false},
+		// RetryOn 按错误信息的子串匹配
+		{"retryOn match", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout", "503"}}, 1, timeout, true},
+		{"retryOn wrapped", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout"}}, 1, fmt.Errorf("call: %w", timeout), true},
+		{"retryOn mismatch", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout", "503"}}, 1, refused, false},
+		// RetryableError 优先于 RetryOn，但不超过最大执行次数
+		{"override retry", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout"}}, 1, &RetryableError{Err: refused, Retry: true}, true},
+		{"override no retry", RetryPolicy{MaxAttempts: 3, RetryOn: []string{"timeout"}}, 1, &RetryableError{Err: timeout, Retry: false}, false},
+		{"override wrapped", RetryPolicy{MaxAttempts: 3}, 1, fmt.Errorf("http: %w", &RetryableError{Err: refused, Retry: false}), false},
+		{"override last attempt", RetryPolicy{MaxAttempts: 3}, 3, &RetryableError{Err: refused, Retry: true}, false},
+	}
+	for _, test := range tests {
+		if got := test.policy.Retryable(test.attempt, test.err); got != test.retryable {
+			t.Fatalf("%s: retryable %v, want %v", test.name, got, test.retryable)
+		}
+	}
+}
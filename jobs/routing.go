@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultQueue 未设置 queue 的任务所在的队列
+const DefaultQueue = "default"
+
+// QueueName 返回任务所在的队列，未设置时为 DefaultQueue
+func (job *Job) QueueName() string {
+	if job.Queue == "" {
+		return DefaultQueue
+	}
+	return job.Queue
+}
+
+// MatchLabels 判断 worker 的标签是否满足任务要求的所有标签，值需完全一致
+func (job *Job) MatchLabels(labels map[string]string) bool {
+	for name, value := range job.Labels {
+		if v, ok := labels[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRouting 校验队列名及要求的标签
+func (job *Job) validateRouting() error {
+	if strings.ContainsAny(job.Queue, " \t\r\n,=") {
+		return errors.New(fmt.Sprintf("invalid queue name %q", job.Queue))
+	}
+	return ValidateLabels(job.Labels)
+}
+
+// ValidateLabels 校验标签名，标签名不能为空或包含 = , 及空白字符
+func ValidateLabels(labels map[string]string) error {
+	for name, value := range labels {
+		if name == "" || strings.ContainsAny(name, " \t\r\n,=") {
+			return errors.New(fmt.Sprintf("invalid label name %q", name))
+		}
+		if strings.Contains(value, ",") {
+			return errors.New(fmt.Sprintf("invalid label value %q", value))
+		}
+	}
+	return nil
+}
+
+// ParseLabels 解析 "region=eu,gpu=false" 格式的标签，用于启动参数
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return nil, errors.New(fmt.Sprintf("invalid label %q, expected name=value", pair))
+		}
+		labels[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
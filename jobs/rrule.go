@@ -0,0 +1,819 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFC 5545 重复规则，支持以下属性，每行一个:
+//   DTSTART[;TZID=...]:20220601T090000   重复的起始时间，默认为任务开始时间
+//   RRULE:FREQ=MONTHLY;BYDAY=-1FR        可出现多次，取并集
+//   RDATE[;TZID=...]:20220615T090000,...  额外的触发时间
+//   EXDATE[;TZID=...]:20220624T090000,... 排除的触发时间，仅有日期时排除当天所有触发
+// 不含属性名的单行 FREQ=...;BYDAY=... 文本视为 RRULE
+// 与 python-dateutil 一致，DTSTART 本身不满足规则时不会触发
+
+const (
+	freqYearly = iota
+	freqMonthly
+	freqWeekly
+	freqDaily
+	freqHourly
+	freqMinutely
+	freqSecondly
+)
+
+const (
+	// rruleSearchYears 查找下次触发时间时最多向后搜索的年数，与 cron 相同
+	rruleSearchYears = cronSearchYears
+	// rruleMinPeriods 周期较长(如 INTERVAL 较大的 YEARLY)时至少搜索的周期数
+	rruleMinPeriods = 8
+	// rruleMaxCursors 缓存的 COUNT 规则查找位置数，超过后清空
+	rruleMaxCursors = 10000
+)
+
+// rruleCursor 带 COUNT 的规则上次查找到的位置: 第 period 个周期(开始时刻为 start)之前已产生 emitted 次触发
+type rruleCursor struct {
+	period  int
+	emitted int
+	start   time.Time
+}
+
+var (
+	rruleCursorsMu sync.Mutex
+	// rruleCursors 按规则文本、DTSTART 及时区缓存查找位置，调度器每次触发都会重新解析规则
+	rruleCursors = make(map[string]rruleCursor)
+)
+
+var rruleFreqs = map[string]int{
+	"YEARLY":   freqYearly,
+	"MONTHLY":  freqMonthly,
+	"WEEKLY":   freqWeekly,
+	"DAILY":    freqDaily,
+	"HOURLY":   freqHourly,
+	"MINUTELY": freqMinutely,
+	"SECONDLY": freqSecondly,
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// rruleWeekday BYDAY 中的一项，n 为 0 时表示每个周 weekday
+type rruleWeekday struct {
+	weekday time.Weekday
+	n       int
+}
+
+// RRule 解析后的 RRULE
+type RRule struct {
+	freq       int
+	interval   int
+	count      int
+	until      time.Time
+	bySecond   []int
+	byMinute   []int
+	byHour     []int
+	byDay      []rruleWeekday
+	byMonthDay []int
+	byYearDay  []int
+	byWeekNo   []int
+	byMonth    []int
+	bySetPos   []int
+	wkst       time.Weekday
+	// key 缓存查找位置时使用的键
+	key string
+}
+
+// Recurrence 解析后的重复规则集合: DTSTART + RRULE + RDATE - EXDATE
+type Recurrence struct {
+	// dtstart 以 UTC 表示的挂钟时间
+	dtstart  time.Time
+	location *time.Location
+	rules    []*RRule
+	rdates   []time.Time
+	exdates  []time.Time
+	// exdays 仅有日期的 EXDATE，以 UTC 表示
+	exdays []time.Time
+}
+
+// ParseRecurrence 解析 RFC 5545 重复规则文本，start 为未指定 DTSTART 时的起始时间
+func ParseRecurrence(text string, start time.Time, loc *time.Location) (*Recurrence, error) {
+	r := &Recurrence{location: loc}
+	var rrules []string
+	var rdates, exdates []string
+	hasStart := false
+
+	for _, line := range unfoldLines(text) {
+		name, params, value, err := splitContentLine(line)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "DTSTART":
+			if hasStart {
+				return nil, errors.New("rrule: multiple DTSTART properties")
+			}
+			t, l, _, err := parseRRuleTime(value, params, loc)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("rrule: DTSTART: %s", err.Error()))
+			}
+			r.dtstart, r.location = t, l
+			hasStart = true
+		case "RRULE":
+			rrules = append(rrules, value)
+		case "RDATE":
+			rdates = append(rdates, line)
+		case "EXDATE":
+			exdates = append(exdates, line)
+		default:
+			return nil, errors.New(fmt.Sprintf("rrule: unsupported property %q", name))
+		}
+	}
+	if len(rrules) == 0 && len(rdates) == 0 {
+		return nil, errors.New("rrule: at least one RRULE or RDATE is required")
+	}
+	if !hasStart {
+		s := start.In(loc)
+		r.dtstart = time.Date(s.Year(), s.Month(), s.Day(), s.Hour(), s.Minute(), s.Second(), 0, time.UTC)
+	}
+
+	for _, value := range rrules {
+		rule, err := parseRRule(value, r)
+		if err != nil {
+			return nil, err
+		}
+		// 搜索范围内找不到触发时间的规则视为永远不会触发
+		if _, ok := rule.next(r, time.Time{}); !ok {
+			return nil, errors.New(fmt.Sprintf("rrule: %q never matches after DTSTART", value))
+		}
+		r.rules = append(r.rules, rule)
+	}
+	for _, line := range rdates {
+		_, params, value, _ := splitContentLine(line)
+		for _, v := range strings.Split(value, ",") {
+			t, l, dateOnly, err := parseRRuleTime(v, params, r.location)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("rrule: RDATE: %s", err.Error()))
+			}
+			if dateOnly {
+				t = time.Date(t.Year(), t.Month(), t.Day(), r.dtstart.Hour(), r.dtstart.Minute(), r.dtstart.Second(), 0, time.UTC)
+			}
+			r.rdates = append(r.rdates, ResolveWallClock(t, l))
+		}
+	}
+	sort.Slice(r.rdates, func(i, j int) bool { return r.rdates[i].Before(r.rdates[j]) })
+	for _, line := range exdates {
+		_, params, value, _ := splitContentLine(line)
+		for _, v := range strings.Split(value, ",") {
+			t, l, dateOnly, err := parseRRuleTime(v, params, r.location)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("rrule: EXDATE: %s", err.Error()))
+			}
+			if dateOnly {
+				r.exdays = append(r.exdays, t)
+			} else {
+				r.exdates = append(r.exdates, ResolveWallClock(t, l))
+			}
+		}
+	}
+	return r, nil
+}
+
+// unfoldLines 按 RFC 5545 展开折行并去掉空行
+func unfoldLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitContentLine 将 NAME;PARAM=VALUE:VALUE 拆分为属性名、参数与值
+func splitContentLine(line string) (string, map[string]string, string, error) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		if strings.Contains(line, "=") {
+			return "RRULE", nil, line, nil
+		}
+		return "", nil, "", errors.New(fmt.Sprintf("rrule: invalid line %q", line))
+	}
+	parts := strings.Split(line[:i], ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, "", errors.New(fmt.Sprintf("rrule: invalid parameter %q", p))
+		}
+		params[strings.ToUpper(kv[0])] = kv[1]
+	}
+	return strings.ToUpper(parts[0]), params, line[i+1:], nil
+}
+
+// parseRRuleTime 解析 DATE 或 DATE-TIME 值，返回以 UTC 表示的挂钟时间、所在时区及是否仅有日期
+func parseRRuleTime(value string, params map[string]string, loc *time.Location) (time.Time, *time.Location, bool, error) {
+	if tzid, ok := params["TZID"]; ok {
+		l, err := LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, nil, false, err
+		}
+		loc = l
+	}
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, loc, false, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t, loc, true, nil
+	}
+	return time.Time{}, nil, false, errors.New(fmt.Sprintf("invalid date-time %q", value))
+}
+
+func parseRRule(value string, r *Recurrence) (*RRule, error) {
+	rule := &RRule{freq: -1, interval: 1, wkst: time.Monday}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New(fmt.Sprintf("rrule: invalid rule part %q", part))
+		}
+		name, v := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		if seen[name] {
+			return nil, errors.New(fmt.Sprintf("rrule: duplicate rule part %s", name))
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			freq, ok := rruleFreqs[v]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("rrule: invalid FREQ value %q", kv[1]))
+			}
+			rule.freq = freq
+		case "INTERVAL":
+			rule.interval, err = strconv.Atoi(v)
+			if err != nil || rule.interval < 1 {
+				return nil, errors.New(fmt.Sprintf("rrule: invalid INTERVAL value %q", kv[1]))
+			}
+		case "COUNT":
+			rule.count, err = strconv.Atoi(v)
+			if err != nil || rule.count < 1 {
+				return nil, errors.New(fmt.Sprintf("rrule: invalid COUNT value %q", kv[1]))
+			}
+		case "UNTIL":
+			t, l, dateOnly, err := parseRRuleTime(v, nil, r.location)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("rrule: invalid UNTIL value %q", kv[1]))
+			}
+			if dateOnly {
+				// 仅有日期时包含当天
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			rule.until = ResolveWallClock(t, l)
+		case "BYSECOND":
+			rule.bySecond, err = parseRRuleInts(name, v, 0, 60, false)
+		case "BYMINUTE":
+			rule.byMinute, err = parseRRuleInts(name, v, 0, 59, false)
+		case "BYHOUR":
+			rule.byHour, err = parseRRuleInts(name, v, 0, 23, false)
+		case "BYMONTHDAY":
+			rule.byMonthDay, err = parseRRuleInts(name, v, 1, 31, true)
+		case "BYYEARDAY":
+			rule.byYearDay, err = parseRRuleInts(name, v, 1, 366, true)
+		case "BYWEEKNO":
+			rule.byWeekNo, err = parseRRuleInts(name, v, 1, 53, true)
+		case "BYMONTH":
+			rule.byMonth, err = parseRRuleInts(name, v, 1, 12, false)
+		case "BYSETPOS":
+			rule.bySetPos, err = parseRRuleInts(name, v, 1, 366, true)
+		case "BYDAY":
+			rule.byDay, err = parseRRuleWeekdays(v)
+		case "WKST":
+			wkst, ok := rruleWeekdays[v]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("rrule: invalid WKST value %q", kv[1]))
+			}
+			rule.wkst = wkst
+		default:
+			return nil, errors.New(fmt.Sprintf("rrule: unsupported rule part %s", name))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	rule.setDefaults(r.dtstart)
+	if !rule.monthDaysPossible() {
+		return nil, errors.New(fmt.Sprintf("rrule: BYMONTHDAY never occurs in the months of %q", value))
+	}
+	rule.key = value + "|" + r.dtstart.Format(time.RFC3339) + "|" + r.location.String()
+	return rule, nil
+}
+
+func parseRRuleInts(name, value string, min, max int, signed bool) ([]int, error) {
+	var result []int
+	for _, s := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(s)
+		abs := n
+		if signed && n < 0 {
+			abs = -n
+		}
+		if err != nil || abs < min || abs > max || (!signed && n < 0) {
+			return nil, errors.New(fmt.Sprintf("rrule: invalid %s value %q", name, s))
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func parseRRuleWeekdays(value string) ([]rruleWeekday, error) {
+	var result []rruleWeekday
+	for _, s := range strings.Split(value, ",") {
+		if len(s) < 2 {
+			return nil, errors.New(fmt.Sprintf("rrule: invalid BYDAY value %q", s))
+		}
+		weekday, ok := rruleWeekdays[s[len(s)-2:]]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("rrule: invalid BYDAY value %q", s))
+		}
+		n := 0
+		if prefix := s[:len(s)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, errors.New(fmt.Sprintf("rrule: invalid BYDAY value %q", s))
+			}
+		}
+		result = append(result, rruleWeekday{weekday: weekday, n: n})
+	}
+	return result, nil
+}
+
+// validate 按 RFC 5545 校验各规则部分的组合
+func (rule *RRule) validate() error {
+	if rule.freq < 0 {
+		return errors.New("rrule: FREQ is required")
+	}
+	if rule.count > 0 && !rule.until.IsZero() {
+		return errors.New("rrule: COUNT and UNTIL must not occur in the same rule")
+	}
+	for _, d := range rule.byDay {
+		if d.n == 0 {
+			continue
+		}
+		if rule.freq != freqMonthly && rule.freq != freqYearly {
+			return errors.New("rrule: BYDAY with a numeric value requires FREQ=MONTHLY or FREQ=YEARLY")
+		}
+		if rule.freq == freqYearly && len(rule.byWeekNo) > 0 {
+			return errors.New("rrule: BYDAY with a numeric value must not be used with BYWEEKNO")
+		}
+		if (rule.freq == freqMonthly || len(rule.byMonth) > 0) && (d.n > 5 || d.n < -5) {
+			return errors.New(fmt.Sprintf("rrule: BYDAY value %d%s out of range for a month", d.n, weekdayCode(d.weekday)))
+		}
+	}
+	if len(rule.byMonthDay) > 0 && rule.freq == freqWeekly {
+		return errors.New("rrule: BYMONTHDAY must not be used with FREQ=WEEKLY")
+	}
+	if len(rule.byYearDay) > 0 && (rule.freq == freqDaily || rule.freq == freqWeekly || rule.freq == freqMonthly) {
+		return errors.New("rrule: BYYEARDAY must not be used with FREQ=DAILY, WEEKLY or MONTHLY")
+	}
+	if len(rule.byWeekNo) > 0 && rule.freq != freqYearly {
+		return errors.New("rrule: BYWEEKNO requires FREQ=YEARLY")
+	}
+	if len(rule.bySetPos) > 0 && len(rule.bySecond)+len(rule.byMinute)+len(rule.byHour)+len(rule.byDay)+
+		len(rule.byMonthDay)+len(rule.byYearDay)+len(rule.byWeekNo)+len(rule.byMonth) == 0 {
+		return errors.New("rrule: BYSETPOS requires another BYxxx rule part")
+	}
+	return nil
+}
+
+// setDefaults 未指定日期相关规则时，按 DTSTART 补全
+func (rule *RRule) setDefaults(dtstart time.Time) {
+	if len(rule.byWeekNo)+len(rule.byYearDay)+len(rule.byMonthDay)+len(rule.byDay) > 0 {
+		return
+	}
+	switch rule.freq {
+	case freqYearly:
+		if len(rule.byMonth) == 0 {
+			rule.byMonth = []int{int(dtstart.Month())}
+		}
+		rule.byMonthDay = []int{dtstart.Day()}
+	case freqMonthly:
+		rule.byMonthDay = []int{dtstart.Day()}
+	case freqWeekly:
+		rule.byDay = []rruleWeekday{{weekday: dtstart.Weekday()}}
+	}
+}
+
+// monthDaysPossible 判断 BYMONTHDAY 是否在 BYMONTH 指定的某个月中存在，如 BYMONTH=2;BYMONTHDAY=30 永远不会触发
+func (rule *RRule) monthDaysPossible() bool {
+	if len(rule.byMonthDay) == 0 {
+		return true
+	}
+	months := rule.byMonth
+	if len(months) == 0 {
+		months = []int{1}
+	}
+	for _, m := range months {
+		// 闰年 2 月有 29 天
+		total := daysIn(2000, time.Month(m))
+		for _, n := range rule.byMonthDay {
+			if n <= total && -n <= total {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func weekdayCode(weekday time.Weekday) string {
+	for code, w := range rruleWeekdays {
+		if w == weekday {
+			return code
+		}
+	}
+	return ""
+}
+
+// Next 返回严格晚于 prev 的下一次触发时间，prev 为零值时返回首次触发时间
+func (r *Recurrence) Next(prev time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, rule := range r.rules {
+		after := prev
+		for {
+			t, ok := rule.next(r, after)
+			if !ok {
+				break
+			}
+			if !r.excluded(t) {
+				if !found || t.Before(next) {
+					next, found = t, true
+				}
+				break
+			}
+			after = t
+		}
+	}
+	for _, t := range r.rdates {
+		if (prev.IsZero() || t.After(prev)) && !r.excluded(t) {
+			if !found || t.Before(next) {
+				next, found = t, true
+			}
+			break
+		}
+	}
+	return next, found
+}
+
+func (r *Recurrence) excluded(t time.Time) bool {
+	for _, ex := range r.exdates {
+		if ex.Equal(t) {
+			return true
+		}
+	}
+	if len(r.exdays) > 0 {
+		local := t.In(r.location)
+		for _, day := range r.exdays {
+			if day.Year() == local.Year() && day.Month() == local.Month() && day.Day() == local.Day() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// next 返回该规则严格晚于 prev 的下一次触发时间，最多搜索到 prev(或 DTSTART)之后 rruleSearchYears 年
+func (rule *RRule) next(r *Recurrence, prev time.Time) (time.Time, bool) {
+	start := ResolveWallClock(r.dtstart, r.location)
+	base := r.dtstart
+	if prev.After(start) {
+		p := prev.In(r.location)
+		base = time.Date(p.Year(), p.Month(), p.Day(), p.Hour(), p.Minute(), p.Second(), 0, time.UTC)
+	}
+	limit := base.AddDate(rruleSearchYears, 0, 0)
+
+	k, emitted := 0, 0
+	if rule.count > 0 {
+		// 有 COUNT 限制时需从第一个周期起计数，从上次查找到的位置继续
+		k, emitted = rule.resume(prev)
+	} else if prev.After(start) {
+		// 没有 COUNT 限制时可直接跳到 prev 所在周期
+		k = rule.periodsBetween(r.dtstart, base)/rule.interval - 1
+		if k < 0 {
+			k = 0
+		}
+	}
+
+	for scanned := 0; ; scanned++ {
+		periodStart := rule.periodStart(r.dtstart, k*rule.interval)
+		if (periodStart.After(limit) && scanned >= rruleMinPeriods) || periodStart.Year() > 9999 {
+			return time.Time{}, false
+		}
+		// 小时及更短的周期在日期不匹配时直接跳到下一天
+		if rule.freq >= freqHourly && !rule.dayMatches(periodStart.Truncate(24*time.Hour)) {
+			nextDay := periodStart.Truncate(24*time.Hour).AddDate(0, 0, 1)
+			k = (rule.periodsBetween(r.dtstart, nextDay) + rule.interval - 1) / rule.interval
+			continue
+		}
+		before := emitted
+		for _, w := range rule.expand(periodStart, r.dtstart) {
+			if w.Before(r.dtstart) {
+				continue
+			}
+			t := ResolveWallClock(w, r.location)
+			if !rule.until.IsZero() && t.After(rule.until) {
+				return time.Time{}, false
+			}
+			emitted++
+			if rule.count > 0 && emitted > rule.count {
+				return time.Time{}, false
+			}
+			if prev.IsZero() || t.After(prev) {
+				if rule.count > 0 {
+					rule.save(rruleCursor{period: k, emitted: before, start: ResolveWallClock(periodStart, r.location)})
+				}
+				return t, true
+			}
+		}
+		k++
+	}
+}
+
+// resume 返回 COUNT 规则可以继续查找的周期及此前已产生的触发次数，该周期需不晚于 prev
+func (rule *RRule) resume(prev time.Time) (int, int) {
+	if prev.IsZero() {
+		return 0, 0
+	}
+	rruleCursorsMu.Lock()
+	defer rruleCursorsMu.Unlock()
+	if cursor, ok := rruleCursors[rule.key]; ok && !cursor.start.After(prev) {
+		return cursor.period, cursor.emitted
+	}
+	return 0, 0
+}
+
+// save 保存 COUNT 规则查找到的位置，只向后移动
+func (rule *RRule) save(cursor rruleCursor) {
+	rruleCursorsMu.Lock()
+	defer rruleCursorsMu.Unlock()
+	if old, ok := rruleCursors[rule.key]; ok && old.period >= cursor.period {
+		return
+	}
+	if len(rruleCursors) >= rruleMaxCursors {
+		rruleCursors = make(map[string]rruleCursor)
+	}
+	rruleCursors[rule.key] = cursor
+}
+
+// periodStart 返回 DTSTART 所在周期之后第 n 个周期的开始时间(UTC 表示的挂钟时间)
+func (rule *RRule) periodStart(dtstart time.Time, n int) time.Time {
+	y, m, d := dtstart.Date()
+	switch rule.freq {
+	case freqYearly:
+		return time.Date(y+n, 1, 1, 0, 0, 0, 0, time.UTC)
+	case freqMonthly:
+		return time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	case freqWeekly:
+		ws := rule.weekStart(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+		return ws.AddDate(0, 0, 7*n)
+	case freqDaily:
+		return time.Date(y, m, d+n, 0, 0, 0, 0, time.UTC)
+	case freqHourly:
+		return time.Date(y, m, d, dtstart.Hour()+n, 0, 0, 0, time.UTC)
+	case freqMinutely:
+		return time.Date(y, m, d, dtstart.Hour(), dtstart.Minute()+n, 0, 0, time.UTC)
+	}
+	return time.Date(y, m, d, dtstart.Hour(), dtstart.Minute(), dtstart.Second()+n, 0, time.UTC)
+}
+
+// periodsBetween 返回从 DTSTART 所在周期到 w 所在周期经过的周期数
+func (rule *RRule) periodsBetween(dtstart, w time.Time) int {
+	switch rule.freq {
+	case freqYearly:
+		return w.Year() - dtstart.Year()
+	case freqMonthly:
+		return (w.Year()-dtstart.Year())*12 + int(w.Month()) - int(dtstart.Month())
+	case freqWeekly:
+		return int(rule.weekStart(w).Sub(rule.weekStart(dtstart)) / (7 * 24 * time.Hour))
+	case freqDaily:
+		return int(w.Truncate(24*time.Hour).Sub(dtstart.Truncate(24*time.Hour)) / (24 * time.Hour))
+	case freqHourly:
+		return int(w.Truncate(time.Hour).Sub(dtstart.Truncate(time.Hour)) / time.Hour)
+	case freqMinutely:
+		return int(w.Truncate(time.Minute).Sub(dtstart.Truncate(time.Minute)) / time.Minute)
+	}
+	return int(w.Sub(dtstart) / time.Second)
+}
+
+// weekStart 返回 day 所在周(以 WKST 为一周开始)的第一天
+func (rule *RRule) weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) - int(rule.wkst) + 7) % 7
+	return time.Date(day.Year(), day.Month(), day.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// expand 返回一个周期内的所有候选时间(已排序并应用 BYSETPOS)
+func (rule *RRule) expand(periodStart, dtstart time.Time) []time.Time {
+	var days []time.Time
+	y, m, d := periodStart.Date()
+	switch rule.freq {
+	case freqYearly:
+		for day := time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC); day.Year() == y; day = day.AddDate(0, 0, 1) {
+			days = append(days, day)
+		}
+	case freqMonthly:
+		for day := time.Date(y, m, 1, 0, 0, 0, 0, time.UTC); day.Month() == m; day = day.AddDate(0, 0, 1) {
+			days = append(days, day)
+		}
+	case freqWeekly:
+		for i := 0; i < 7; i++ {
+			days = append(days, time.Date(y, m, d+i, 0, 0, 0, 0, time.UTC))
+		}
+	default:
+		days = append(days, time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	}
+
+	hours := rule.timeValues(rule.byHour, freqHourly, periodStart.Hour(), dtstart.Hour())
+	minutes := rule.timeValues(rule.byMinute, freqMinutely, periodStart.Minute(), dtstart.Minute())
+	seconds := rule.timeValues(rule.bySecond, freqSecondly, periodStart.Second(), dtstart.Second())
+
+	var candidates []time.Time
+	for _, day := range days {
+		if !rule.dayMatches(day) {
+			continue
+		}
+		for _, h := range hours {
+			for _, mi := range minutes {
+				for _, s := range seconds {
+					candidates = append(candidates, time.Date(day.Year(), day.Month(), day.Day(), h, mi, s, 0, time.UTC))
+				}
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+
+	if len(rule.bySetPos) == 0 {
+		return candidates
+	}
+	var selected []time.Time
+	for _, pos := range rule.bySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(candidates) + pos
+		}
+		if i >= 0 && i < len(candidates) {
+			selected = append(selected, candidates[i])
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Before(selected[j]) })
+	return selected
+}
+
+// timeValues 返回时、分、秒的候选值: 比 unit 粗的频率按 BYxxx 展开，否则只保留周期本身的值
+func (rule *RRule) timeValues(by []int, unit, periodValue, startValue int) []int {
+	if rule.freq >= unit {
+		if len(by) == 0 || containsInt(by, periodValue) {
+			return []int{periodValue}
+		}
+		return nil
+	}
+	if len(by) == 0 {
+		return []int{startValue}
+	}
+	values := append([]int(nil), by...)
+	sort.Ints(values)
+	return values
+}
+
+func (rule *RRule) dayMatches(day time.Time) bool {
+	y, m, d := day.Date()
+	if len(rule.byMonth) > 0 && !containsInt(rule.byMonth, int(m)) {
+		return false
+	}
+	if len(rule.byWeekNo) > 0 && !rule.weekNoMatches(day) {
+		return false
+	}
+	if len(rule.byYearDay) > 0 {
+		yday, total := day.YearDay(), daysInYear(y)
+		matched := false
+		for _, n := range rule.byYearDay {
+			if n == yday || (n < 0 && total+1+n == yday) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.byMonthDay) > 0 {
+		total := daysIn(y, m)
+		matched := false
+		for _, n := range rule.byMonthDay {
+			if n == d || (n < 0 && total+1+n == d) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.byDay) > 0 {
+		matched := false
+		for _, wd := range rule.byDay {
+			if wd.weekday != day.Weekday() {
+				continue
+			}
+			if wd.n == 0 || rule.nthWeekdayMatches(day, wd.n) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// nthWeekdayMatches 判断 day 是否为所在月(或年)的第 n 个同名星期，n 为负数时从末尾数起
+func (rule *RRule) nthWeekdayMatches(day time.Time, n int) bool {
+	y, m, d := day.Date()
+	var index, total int
+	if rule.freq == freqMonthly || len(rule.byMonth) > 0 {
+		index = (d-1)/7 + 1
+		total = index + (daysIn(y, m)-d)/7
+	} else {
+		yday := day.YearDay()
+		index = (yday-1)/7 + 1
+		total = index + (daysInYear(y)-yday)/7
+	}
+	if n > 0 {
+		return index == n
+	}
+	return total+1+n == index
+}
+
+// weekNoMatches 判断 day 是否在 BYWEEKNO 指定的周内，第1周为至少包含4天的第一周
+func (rule *RRule) weekNoMatches(day time.Time) bool {
+	y := day.Year()
+	jan1 := time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	firstWeekStart := rule.weekStart(jan1)
+	// 1月1日所在周少于4天属于本年时，第1周从下一周开始
+	if jan1.Sub(firstWeekStart) >= 4*24*time.Hour {
+		firstWeekStart = firstWeekStart.AddDate(0, 0, 7)
+	}
+	nextJan1 := time.Date(y+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextFirstWeekStart := rule.weekStart(nextJan1)
+	if nextJan1.Sub(nextFirstWeekStart) >= 4*24*time.Hour {
+		nextFirstWeekStart = nextFirstWeekStart.AddDate(0, 0, 7)
+	}
+	if day.Before(firstWeekStart) || !day.Before(nextFirstWeekStart) {
+		return false
+	}
+	weekNo := int(day.Sub(firstWeekStart)/(7*24*time.Hour)) + 1
+	totalWeeks := int(nextFirstWeekStart.Sub(firstWeekStart) / (7 * 24 * time.Hour))
+	for _, n := range rule.byWeekNo {
+		if n == weekNo || (n < 0 && totalWeeks+1+n == weekNo) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
@@ -0,0 +1,240 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rruleLayout = "20060102T150405"
+
+// occurrences 返回重复规则的前 n 次触发时间(以 loc 的挂钟时间表示)，不足 n 次时返回全部
+func occurrences(t *testing.T, text string, loc *time.Location, n int) []string {
+	r, err := ParseRecurrence(text, time.Time{}, loc)
+	if err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	var result []string
+	var prev time.Time
+	for len(result) < n {
+		next, ok := r.Next(prev)
+		if !ok {
+			break
+		}
+		result = append(result, next.In(loc).Format(rruleLayout))
+		prev = next
+	}
+	return result
+}
+
+// days 将 "19970902,03" 形式的日期列表展开为同一时刻的触发时间，逗号后只写日期时沿用前一项的年月
+func days(clock string, list ...string) []string {
+	var result []string
+	for _, group := range list {
+		parts := strings.Split(group, ",")
+		prefix := parts[0][:6]
+		for i, part := range parts {
+			if i > 0 {
+				part = prefix + part
+			}
+			result = append(result, part+"T"+clock)
+		}
+	}
+	return result
+}
+
+// RFC 5545 3.8.5.3 中的示例，DTSTART 为 America/New_York 时间
+func TestRRuleRFC5545Examples(t *testing.T) {
+	loc := mustLoadLocation(t, "America/New_York")
+	tests := []struct {
+		name    string
+		dtstart string
+		rule    string
+		want    []string
+		// exact 为 true 时规则在 want 之后不再触发
+		exact bool
+	}{
+		{"daily for 10 occurrences", "19970902T090000", "FREQ=DAILY;COUNT=10",
+			days("090000", "19970902,03,04,05,06,07,08,09,10,11"), true},
+		{"every other day", "19970902T090000", "FREQ=DAILY;INTERVAL=2",
+			days("090000", "19970902,04,06,08,10"), false},
+		{"every 10 days, 5 occurrences", "19970902T090000", "FREQ=DAILY;INTERVAL=10;COUNT=5",
+			days("090000", "19970902,12,22", "19971002,12"), true},
+		{"weekly for 10 occurrences", "19970902T090000", "FREQ=WEEKLY;COUNT=10",
+			days("090000", "19970902,09,16,23,30", "19971007,14,21,28", "19971104"), true},
+		{"weekly on tuesday and thursday for five weeks", "19970902T090000", "FREQ=WEEKLY;UNTIL=19971007T000000Z;WKST=SU;BYDAY=TU,TH",
+			days("090000", "19970902,04,09,11,16,18,23,25,30", "19971002"), true},
+		// DTSTART(周二)本身不满足规则，不触发
+		{"every other week on monday, wednesday and friday", "19970902T090000", "FREQ=WEEKLY;INTERVAL=2;UNTIL=19971224T000000Z;WKST=SU;BYDAY=MO,WE,FR",
+			days("090000", "19970903,05,15,17,19,29", "19971001,03,13,15,17,27,29,31", "19971110,12,14,24,26,28", "19971208,10,12,22"), true},
+		{"monthly on the first friday for 10 occurrences", "19970905T090000", "FREQ=MONTHLY;COUNT=10;BYDAY=1FR",
+			days("090000", "19970905", "19971003", "19971107", "19971205", "19980102", "19980206", "19980306", "19980403", "19980501", "19980605"), true},
+		{"every other month on the first and last sunday", "19970907T090000", "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU",
+			days("090000", "19970907,28", "19971102,30", "19980104,25", "19980301,29", "19980503,31"), true},
+		{"monthly on the second-to-last monday for 6 months", "19970922T090000", "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO",
+			days("090000", "19970922", "19971020", "19971117", "19971222", "19980119", "19980216"), true},
+		{"monthly on the third-to-the-last day", "19970928T090000", "FREQ=MONTHLY;BYMONTHDAY=-3",
+			days("090000", "19970928", "19971029", "19971128", "19971229", "19980129", "19980226"), false},
+		{"monthly on the 2nd and 15th for 10 occurrences", "19970902T090000", "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=2,15",
+			days("090000", "19970902,15", "19971002,15", "19971102,15", "19971202,15", "19980102,15"), true},
+		{"yearly in june and july for 10 occurrences", "19970610T090000", "FREQ=YEARLY;COUNT=10;BYMONTH=6,7",
+			days("090000", "19970610", "19970710", "19980610", "19980710", "19990610", "19990710", "20000610", "20000710", "20010610", "20010710"), true},
+		{"every third year on the 1st, 100th and 200th day", "19970101T090000", "FREQ=YEARLY;INTERVAL=3;COUNT=10;BYYEARDAY=1,100,200",
+			days("090000", "19970101", "19970410", "19970719", "20000101", "20000409", "20000718", "20030101", "20030410", "20030719", "20060101"), true},
+		{"every 20th monday of the year", "19970519T090000", "FREQ=YEARLY;BYDAY=20MO",
+			days("090000", "19970519", "19980518", "19990517"), false},
+		{"monday of week number 20", "19970512T090000", "FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO",
+			days("090000", "19970512", "19980511", "19990517"), false},
+		{"every thursday in march", "19970313T090000", "FREQ=YEARLY;BYMONTH=3;BYDAY=TH",
+			days("090000", "19970313,20,27", "19980305,12,19,26", "19990304"), false},
+		// DTSTART 不是 13 号周五，不触发
+		{"every friday the 13th", "19970902T090000", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			days("090000", "19980213", "19980313", "19981113", "19990813", "20001013"), false},
+		{"first saturday that follows the first sunday", "19970913T090000", "FREQ=MONTHLY;BYDAY=SA;BYMONTHDAY=7,8,9,10,11,12,13",
+			days("090000", "19970913", "19971011", "19971108", "19971213", "19980110", "19980207", "19980307"), false},
+		{"us presidential election day", "19961105T090000", "FREQ=YEARLY;INTERVAL=4;BYMONTH=11;BYDAY=TU;BYMONTHDAY=2,3,4,5,6,7,8",
+			days("090000", "19961105", "20001107", "20041102"), false},
+		{"third instance of tuesday, wednesday or thursday", "19970904T090000", "FREQ=MONTHLY;COUNT=3;BYDAY=TU,WE,TH;BYSETPOS=3",
+			days("090000", "19970904", "19971007", "19971106"), true},
+		{"second-to-last weekday of the month", "19970929T090000", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-2",
+			days("090000", "19970929", "19971030", "19971127", "19971230", "19980129", "19980226", "19980330"), false},
+		{"every 15 minutes for 6 occurrences", "19970902T090000", "FREQ=MINUTELY;INTERVAL=15;COUNT=6",
+			[]string{"19970902T090000", "19970902T091500", "19970902T093000", "19970902T094500", "19970902T100000", "19970902T101500"}, true},
+		{"every 20 minutes from 9:00 to 16:40", "19970902T090000", "FREQ=DAILY;BYHOUR=9,10,11,12,13,14,15,16;BYMINUTE=0,20,40",
+			[]string{"19970902T090000", "19970902T092000", "19970902T094000", "19970902T100000"}, false},
+		{"week start monday", "19970805T090000", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			days("090000", "19970805,10,19,24"), true},
+		{"week start sunday", "19970805T090000", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			days("090000", "19970805,17,19,31"), true},
+		// 不存在的日期(2 月 30 日)被忽略
+		{"invalid dates are ignored", "20070115T090000", "FREQ=MONTHLY;BYMONTHDAY=15,30;COUNT=5",
+			days("090000", "20070115,30", "20070215", "20070315,30"), true},
+	}
+	for _, test := range tests {
+		text := fmt.Sprintf("DTSTART;TZID=America/New_York:%s\nRRULE:%s", test.dtstart, test.rule)
+		n := len(test.want)
+		if test.exact {
+			n++
+		}
+		got := occurrences(t, text, loc, n)
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Fatalf("%s:\n got %v\nwant %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRRuleDaily20Minutes(t *testing.T) {
+	loc := mustLoadLocation(t, "America/New_York")
+	got := occurrences(t, "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=DAILY;BYHOUR=9,10,11,12,13,14,15,16;BYMINUTE=0,20,40", loc, 25)
+	// 每天 24 次，16:40 之后为次日 9:00
+	if got[23] != "19970902T164000" || got[24] != "19970903T090000" {
+		t.Fatalf("fires %v", got[22:])
+	}
+}
+
+func TestRecurrenceExdateRdate(t *testing.T) {
+	loc := mustLoadLocation(t, "America/New_York")
+	text := `DTSTART;TZID=America/New_York:19970902T090000
+RRULE:FREQ=DAILY;COUNT=5
+EXDATE;TZID=America/New_York:19970903T090000
+EXDATE:19970905
+RDATE;TZID=America/New_York:19970910T090000`
+	// 被排除的执行仍计入 COUNT
+	want := days("090000", "19970902,04,06,10")
+	if got := occurrences(t, text, loc, 10); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestRRuleUntilDate(t *testing.T) {
+	loc := mustLoadLocation(t, "America/New_York")
+	// 仅有日期的 UNTIL 包含当天
+	got := occurrences(t, "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:FREQ=DAILY;UNTIL=19970905", loc, 10)
+	if want := days("090000", "19970902,03,04,05"); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseRecurrenceInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"COUNT=3",
+		"FREQ=FORTNIGHTLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;COUNT=2;UNTIL=19971224T000000Z",
+		"FREQ=DAILY;BYHOUR=24",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYYEARDAY=1",
+		"FREQ=DAILY;BYWEEKNO=1",
+		"FREQ=DAILY;BYSETPOS=1",
+		"FREQ=DAILY;BYDAY=XX",
+		"FREQ=DAILY;FOO=1",
+	} {
+		text := "DTSTART;TZID=America/New_York:19970902T090000\nRRULE:" + rule
+		if _, err := ParseRecurrence(text, time.Time{}, time.UTC); err == nil {
+			t.Fatalf("%s: expected an error", rule)
+		}
+	}
+}
+
+func TestRRuleNeverMatches(t *testing.T) {
+	begin := time.Now()
+	for _, test := range []struct {
+		dtstart string
+		rule    string
+	}{
+		{"19970902T090000", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30"},
+		{"19970902T090000", "FREQ=SECONDLY;BYMONTH=4,6;BYMONTHDAY=31"},
+		// 间隔 4 年且从非闰年开始，永远不会遇到 2 月 29 日
+		{"20210301T090000", "FREQ=YEARLY;INTERVAL=4;BYMONTH=2;BYMONTHDAY=29"},
+		// 每 168 小时触发一次，始终落在 DTSTART 所在的周一
+		{"19970901T090000", "FREQ=HOURLY;INTERVAL=168;BYDAY=SA"},
+	} {
+		text := fmt.Sprintf("DTSTART:%s\nRRULE:%s", test.dtstart, test.rule)
+		if _, err := ParseRecurrence(text, time.Time{}, time.UTC); err == nil {
+			t.Fatalf("%s: expected an error", test.rule)
+		}
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("rejecting rules took %s", elapsed)
+	}
+
+	// 触发间隔超过 5 年的规则仍可触发
+	got := occurrences(t, "DTSTART:20220101T090000\nRRULE:FREQ=YEARLY;INTERVAL=3;BYMONTH=2;BYMONTHDAY=29", time.UTC, 2)
+	if want := []string{"20280229T090000", "20400229T090000"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestRRuleCountResumes(t *testing.T) {
+	const count = 20000
+	text := "DTSTART:20220101T000000\nRRULE:FREQ=MINUTELY;COUNT=" + fmt.Sprint(count)
+	start := utc(2022, 1, 1, 0, 0, 0)
+	// 与调度器相同，每次触发后重新解析规则再计算下次触发时间
+	var prev time.Time
+	for i := 0; i < count; i++ {
+		r, err := ParseRecurrence(text, time.Time{}, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next, ok := r.Next(prev)
+		if want := start.Add(time.Duration(i) * time.Minute); !ok || !next.Equal(want) {
+			t.Fatalf("fire %d at %s (ok %v), want %s", i, next, ok, want)
+		}
+		prev = next
+	}
+	r, _ := ParseRecurrence(text, time.Time{}, time.UTC)
+	if next, ok := r.Next(prev); ok {
+		t.Fatalf("fired at %s after %d occurrences", next, count)
+	}
+	// 查找从上次的位置继续
+	rruleCursorsMu.Lock()
+	cursor := rruleCursors[r.rules[0].key]
+	rruleCursorsMu.Unlock()
+	if cursor.period != count-1 || cursor.emitted != count-1 {
+		t.Fatalf("cursor at period %d with %d emitted", cursor.period, cursor.emitted)
+	}
+}
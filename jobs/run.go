@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"time"
+)
+
+// 任务一次执行的状态
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	// RunTimedOut 超过任务的 Timeout 仍未返回
+	RunTimedOut = "timed_out"
+	// RunCancelled 任务被删除或调度器关闭时被取消
+	RunCancelled = "cancelled"
+)
+
+// RunRecord 任务一次执行的记录
+type RunRecord struct {
+	Id string `json:"id"`
+	// JobId 重试的执行记录在原任务下
+	JobId   string `json:"jobId"`
+	JobName string `json:"jobName"`
+	// ScheduledTime 计划执行时间，StartTime、EndTime 实际开始及结束时间
+	ScheduledTime time.Time `json:"scheduledTime"`
+	StartTime     time.Time `json:"startTime"`
+	EndTime       time.Time `json:"endTime"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	// Result 函数返回值的 JSON 数组
+	Result json.RawMessage `json:"result,omitempty"`
+	// Attempt 第几次执行(从1开始)，大于1表示重试
+	Attempt int `json:"attempt"`
+	// Output 执行期间写入的输出，超过大小上限时截断
+	Output          string `json:"output,omitempty"`
+	OutputTruncated bool   `json:"outputTruncated,omitempty"`
+}
+
+// Duration 返回执行耗时
+func (record *RunRecord) Duration() time.Duration {
+	return record.EndTime.Sub(record.StartTime)
+}
+
+func (record *RunRecord) Bytes() []byte {
+	// 使用 encoding/gob 序列化
+	buf := new(bytes.Buffer)
+	_ = gob.NewEncoder(buf).Encode(record)
+	return buf.Bytes()
+}
+
+func BytesToRunRecord(b []byte) *RunRecord {
+	// 使用 encoding/gob 反序列化
+	var record RunRecord
+	_ = gob.NewDecoder(bytes.NewBuffer(b)).Decode(&record)
+	return &record
+}
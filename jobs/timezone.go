@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LoadLocation 按 IANA 名称加载时区，名称为空时使用服务器本地时区
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid timezone %q: %s", name, err.Error()))
+	}
+	return loc, nil
+}
+
+// ResolveWallClock 将时区 loc 下的挂钟时间转换为具体时刻，夏令时切换时:
+//   - 被跳过的挂钟时间(如 02:30 不存在)按跳过的时长顺延(得到 03:30)
+//   - 重复出现的挂钟时间(如 01:30 出现两次)取第一次出现的时刻
+func ResolveWallClock(wall time.Time, loc *time.Location) time.Time {
+	wall = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+	u := wall.Unix()
+	_, offsetBefore := time.Unix(u-86400, 0).In(loc).Zone()
+	_, offsetAfter := time.Unix(u+86400, 0).In(loc).Zone()
+
+	var resolved time.Time
+	for _, offset := range []int{offsetBefore, offsetAfter} {
+		t := time.Unix(u-int64(offset), int64(wall.Nanosecond())).In(loc)
+		if !sameWallClock(t, wall) {
+			continue
+		}
+		if resolved.IsZero() || t.Before(resolved) {
+			resolved = t
+		}
+	}
+	if resolved.IsZero() {
+		// 落在被跳过的区间内，使用切换前的偏移量，即顺延跳过的时长
+		resolved = time.Unix(u-int64(offsetBefore), int64(wall.Nanosecond())).In(loc)
+	}
+	return resolved
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := wall.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2 &&
+		t.Hour() == wall.Hour() && t.Minute() == wall.Minute() && t.Second() == wall.Second()
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+func TestResolveWallClock(t *testing.T) {
+	tests := []struct {
+		zone string
+		wall time.Time
+		want time.Time
+	}{
+		// America/New_York 2022-03-13 02:00 EST 跳到 03:00 EDT
+		{"America/New_York", utc(2022, 3, 13, 1, 30, 0), utc(2022, 3, 13, 6, 30, 0)},
+		{"America/New_York", utc(2022, 3, 13, 2, 30, 0), utc(2022, 3, 13, 7, 30, 0)},
+		{"America/New_York", utc(2022, 3, 13, 3, 30, 0), utc(2022, 3, 13, 7, 30, 0)},
+		// America/New_York 2022-11-06 02:00 EDT 回到 01:00 EST，01:30 取第一次出现的 EDT 时刻
+		{"America/New_York", utc(2022, 11, 6, 0, 30, 0), utc(2022, 11, 6, 4, 30, 0)},
+		{"America/New_York", utc(2022, 11, 6, 1, 30, 0), utc(2022, 11, 6, 5, 30, 0)},
+		{"America/New_York", utc(2022, 11, 6, 2, 30, 0), utc(2022, 11, 6, 7, 30, 0)},
+		// Europe/Berlin 2022-03-27 02:00 CET 跳到 03:00 CEST
+		{"Europe/Berlin", utc(2022, 3, 27, 1, 30, 0), utc(2022, 3, 27, 0, 30, 0)},
+		{"Europe/Berlin", utc(2022, 3, 27, 2, 30, 0), utc(2022, 3, 27, 1, 30, 0)},
+		{"Europe/Berlin", utc(2022, 3, 27, 3, 30, 0), utc(2022, 3, 27, 1, 30, 0)},
+		// Europe/Berlin 2022-10-30 03:00 CEST 回到 02:00 CET
+		{"Europe/Berlin", utc(2022, 10, 30, 2, 30, 0), utc(2022, 10, 30, 0, 30, 0)},
+		{"Europe/Berlin", utc(2022, 10, 30, 3, 30, 0), utc(2022, 10, 30, 2, 30, 0)},
+	}
+	for _, test := range tests {
+		loc := mustLoadLocation(t, test.zone)
+		if got := ResolveWallClock(test.wall, loc); !got.Equal(test.want) {
+			t.Fatalf("%s %s: resolved to %s, want %s", test.zone, test.wall.Format(ParseTimeLayout), got.UTC(), test.want)
+		}
+	}
+}
+
+func TestTriggersAcrossDST(t *testing.T) {
+	tests := []struct {
+		name string
+		job  string
+		want []time.Time
+	}{
+		// 固定间隔按实际经过的时间触发，挂钟时间随切换跳过或重复
+		{"interval spring forward", `"interval": "1h", "type": 2, "timezone": "America/New_York", "startTime": "2022-03-13T00:30:00Z"`,
+			[]time.Time{utc(2022, 3, 13, 5, 30, 0), utc(2022, 3, 13, 6, 30, 0), utc(2022, 3, 13, 7, 30, 0), utc(2022, 3, 13, 8, 30, 0)}},
+		{"interval fall back", `"interval": "1h", "type": 2, "timezone": "Europe/Berlin", "startTime": "2022-10-30T01:30:00Z"`,
+			[]time.Time{utc(2022, 10, 29, 23, 30, 0), utc(2022, 10, 30, 0, 30, 0), utc(2022, 10, 30, 1, 30, 0), utc(2022, 10, 30, 2, 30, 0)}},
+		// 被跳过的 02:30 顺延到 03:30，次日恢复 02:30
+		{"cron daily spring forward", `"cron": "30 2 * * *", "type": 4, "timezone": "America/New_York", "startTime": "2022-03-12T00:00:00Z"`,
+			[]time.Time{utc(2022, 3, 12, 7, 30, 0), utc(2022, 3, 13, 7, 30, 0), utc(2022, 3, 14, 6, 30, 0)}},
+		// 重复的 01:30 只触发第一次
+		{"cron daily fall back", `"cron": "30 1 * * *", "type": 4, "timezone": "America/New_York", "startTime": "2022-11-05T00:00:00Z"`,
+			[]time.Time{utc(2022, 11, 5, 5, 30, 0), utc(2022, 11, 6, 5, 30, 0), utc(2022, 11, 7, 6, 30, 0)}},
+		{"cron hourly spring forward", `"cron": "30 * * * *", "type": 4, "timezone": "America/New_York", "startTime": "2022-03-13T00:00:00Z"`,
+			[]time.Time{utc(2022, 3, 13, 5, 30, 0), utc(2022, 3, 13, 6, 30, 0), utc(2022, 3, 13, 7, 30, 0), utc(2022, 3, 13, 8, 30, 0)}},
+		{"cron hourly fall back", `"cron": "30 * * * *", "type": 4, "timezone": "America/New_York", "startTime": "2022-11-06T00:00:00Z"`,
+			[]time.Time{utc(2022, 11, 6, 4, 30, 0), utc(2022, 11, 6, 5, 30, 0), utc(2022, 11, 6, 7, 30, 0)}},
+		{"cron daily spring forward berlin", `"cron": "30 2 * * *", "type": 4, "timezone": "Europe/Berlin", "startTime": "2022-03-26T00:00:00Z"`,
+			[]time.Time{utc(2022, 3, 26, 1, 30, 0), utc(2022, 3, 27, 1, 30, 0), utc(2022, 3, 28, 0, 30, 0)}},
+		{"cron daily fall back berlin", `"cron": "30 2 * * *", "type": 4, "timezone": "Europe/Berlin", "startTime": "2022-10-29T00:00:00Z"`,
+			[]time.Time{utc(2022, 10, 29, 0, 30, 0), utc(2022, 10, 30, 0, 30, 0), utc(2022, 10, 31, 1, 30, 0)}},
+		{"rrule daily spring forward", `"rrule": "FREQ=DAILY;COUNT=3", "type": 8, "timezone": "America/New_York", "startTime": "2022-03-12T02:30:00Z"`,
+			[]time.Time{utc(2022, 3, 12, 7, 30, 0), utc(2022, 3, 13, 7, 30, 0), utc(2022, 3, 14, 6, 30, 0)}},
+		{"rrule hourly fall back", `"rrule": "FREQ=HOURLY;COUNT=4", "type": 8, "timezone": "America/New_York", "startTime": "2022-11-06T00:30:00Z"`,
+			[]time.Time{utc(2022, 11, 6, 4, 30, 0), utc(2022, 11, 6, 5, 30, 0), utc(2022, 11, 6, 7, 30, 0), utc(2022, 11, 6, 8, 30, 0)}},
+		{"rrule daily fall back berlin", `"rrule": "FREQ=DAILY;COUNT=3", "type": 8, "timezone": "Europe/Berlin", "startTime": "2022-10-29T02:30:00Z"`,
+			[]time.Time{utc(2022, 10, 29, 0, 30, 0), utc(2022, 10, 30, 0, 30, 0), utc(2022, 10, 31, 1, 30, 0)}},
+	}
+	mustLoadLocation(t, "America/New_York")
+	mustLoadLocation(t, "Europe/Berlin")
+	for _, test := range tests {
+		job := newTestJob(t, fmt.Sprintf(`{"funcName": "add", %s}`, test.job))
+		trigger, err := job.NewTrigger()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var prev time.Time
+		for i, want := range test.want {
+			next, ok := trigger.NextFireTime(prev, prev)
+			if !ok || !next.Equal(want) {
+				t.Fatalf("%s: fire %d at %s (ok %v), want %s", test.name, i, next.UTC(), ok, want)
+			}
+			prev = next
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Trigger 计算任务的触发时间
+type Trigger interface {
+	// NextFireTime 返回严格晚于 prev 的下一次触发时间，prev 为零值时返回首次触发时间，
+	// 第二个返回值为 false 表示不再触发
+	NextFireTime(prev, now time.Time) (time.Time, bool)
+}
+
+// TriggerFactory 根据任务及触发器定义创建触发器
+type TriggerFactory func(job *Job, spec TriggerSpec) (Trigger, error)
+
+// TriggerSpec 触发器定义，随任务一起序列化保存在 JobStore 中
+type TriggerSpec struct {
+	Type    string                 `json:"type"`
+	Options map[string]interface{} `json:"options,omitempty"`
+	// Triggers 组合触发器(and / or)的子触发器
+	Triggers []TriggerSpec `json:"triggers,omitempty"`
+}
+
+var (
+	triggersMu sync.RWMutex
+	triggers   = make(map[string]TriggerFactory)
+)
+
+func init() {
+	// 触发器参数中可能包含嵌套的数组及对象
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+	// 注册内置触发器
+	RegisterTrigger(TriggerDate, newDateTrigger)
+	RegisterTrigger(TriggerInterval, newIntervalTrigger)
+	RegisterTrigger(TriggerCron, newCronTrigger)
+	RegisterTrigger(TriggerRRule, newRRuleTrigger)
+	RegisterTrigger(TriggerAnd, newAndTrigger)
+	RegisterTrigger(TriggerOr, newOrTrigger)
+}
+
+// RegisterTrigger 注册触发器，重复注册同名触发器会 panic
+func RegisterTrigger(name string, factory TriggerFactory) {
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+
+	if name == "" {
+		panic("jobs: invalid trigger name")
+	}
+	if factory == nil {
+		panic("jobs: nil trigger factory")
+	}
+	if _, exist := triggers[name]; exist {
+		panic("jobs: multiple registrations for trigger " + name)
+	}
+	triggers[name] = factory
+}
+
+// RegisteredTriggers 返回已注册的触发器名称
+func RegisteredTriggers() []string {
+	triggersMu.RLock()
+	defer triggersMu.RUnlock()
+
+	names := make([]string, 0, len(triggers))
+	for name := range triggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTrigger 根据触发器定义创建触发器
+func NewTrigger(job *Job, spec TriggerSpec) (Trigger, error) {
+	triggersMu.RLock()
+	factory, ok := triggers[spec.Type]
+	triggersMu.RUnlock()
+
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown trigger type %q", spec.Type))
+	}
+	if spec.Options == nil {
+		spec.Options = make(map[string]interface{})
+	}
+	return factory(job, spec)
+}
@@ -0,0 +1,181 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 内置触发器名称
+const (
+	TriggerDate     = "date"
+	TriggerInterval = "interval"
+	TriggerCron     = "cron"
+	TriggerRRule    = "rrule"
+)
+
+// dateTrigger 在指定时间触发一次
+type dateTrigger struct {
+	runDate time.Time
+}
+
+// newDateTrigger 参数: runDate 触发时间，默认为任务开始时间
+func newDateTrigger(job *Job, spec TriggerSpec) (Trigger, error) {
+	runDate, err := optionTime(spec.Options, "runDate", job.Location())
+	if err != nil {
+		return nil, err
+	}
+	if runDate.IsZero() {
+		runDate = job.StartTime
+	}
+	return &dateTrigger{runDate: runDate}, nil
+}
+
+func (t *dateTrigger) NextFireTime(prev, now time.Time) (time.Time, bool) {
+	if prev.IsZero() || t.runDate.After(prev) {
+		return t.runDate, true
+	}
+	return time.Time{}, false
+}
+
+// intervalTrigger 从开始时间起按固定间隔触发，触发时间始终对齐到 startDate + n * interval
+type intervalTrigger struct {
+	startDate time.Time
+	interval  time.Duration
+}
+
+// newIntervalTrigger 参数: interval 间隔秒数，默认为任务的 Interval；startDate 开始时间，默认为任务开始时间
+func newIntervalTrigger(job *Job, spec TriggerSpec) (Trigger, error) {
+	interval, err := optionSeconds(spec.Options, "interval")
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		interval = job.Interval
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval trigger requires a positive interval")
+	}
+	startDate, err := optionTime(spec.Options, "startDate", job.Location())
+	if err != nil {
+		return nil, err
+	}
+	if startDate.IsZero() {
+		startDate = job.StartTime
+	}
+	return &intervalTrigger{startDate: startDate, interval: interval}, nil
+}
+
+func (t *intervalTrigger) NextFireTime(prev, now time.Time) (time.Time, bool) {
+	if prev.IsZero() || prev.Before(t.startDate) {
+		return t.startDate, true
+	}
+	n := prev.Sub(t.startDate)/t.interval + 1
+	return t.startDate.Add(n * t.interval), true
+}
+
+// cronTrigger 按 cron 表达式在任务时区内触发
+type cronTrigger struct {
+	startDate time.Time
+	schedule  *CronSchedule
+	location  *time.Location
+}
+
+// newCronTrigger 参数: expr cron 表达式，默认为任务的 Cron
+func newCronTrigger(job *Job, spec TriggerSpec) (Trigger, error) {
+	expr, err := optionString(spec.Options, "expr")
+	if err != nil {
+		return nil, err
+	}
+	if expr == "" {
+		expr = job.Cron
+	}
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &cronTrigger{startDate: job.StartTime, schedule: schedule, location: job.Location()}, nil
+}
+
+func (t *cronTrigger) NextFireTime(prev, now time.Time) (time.Time, bool) {
+	if prev.IsZero() {
+		// 开始时间本身满足表达式时也应触发
+		prev = t.startDate.Add(-time.Second)
+	}
+	// 存储中的时间只保留了偏移量，需转换回任务时区才能正确处理夏令时
+	return t.schedule.Next(prev.In(t.location))
+}
+
+// rruleTrigger 按 RFC 5545 重复规则触发
+type rruleTrigger struct {
+	recurrence *Recurrence
+}
+
+// newRRuleTrigger 参数: rule RRULE/EXDATE/RDATE 规则文本，默认为任务的 RRule
+func newRRuleTrigger(job *Job, spec TriggerSpec) (Trigger, error) {
+	rule, err := optionString(spec.Options, "rule")
+	if err != nil {
+		return nil, err
+	}
+	if rule == "" {
+		rule = job.RRule
+	}
+	recurrence, err := ParseRecurrence(rule, job.StartTime, job.Location())
+	if err != nil {
+		return nil, err
+	}
+	return &rruleTrigger{recurrence: recurrence}, nil
+}
+
+func (t *rruleTrigger) NextFireTime(prev, now time.Time) (time.Time, bool) {
+	return t.recurrence.Next(prev)
+}
+
+func optionString(options map[string]interface{}, key string) (string, error) {
+	v, ok := options[key]
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("trigger option %q must be a string", key))
+	}
+	return s, nil
+}
+
+// optionSeconds 读取时长参数，数字表示秒数，字符串按 time.ParseDuration 解析
+func optionSeconds(options map[string]interface{}, key string) (time.Duration, error) {
+	v, ok := options[key]
+	if !ok || v == nil {
+		return 0, nil
+	}
+	switch n := v.(type) {
+	case float64:
+		return time.Duration(n * float64(time.Second)), nil
+	case int:
+		return time.Duration(n) * time.Second, nil
+	case int64:
+		return time.Duration(n) * time.Second, nil
+	case string:
+		d, err := time.ParseDuration(n)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("trigger option %q: %s", key, err.Error()))
+		}
+		return d, nil
+	}
+	return 0, errors.New(fmt.Sprintf("trigger option %q must be a number of seconds or a duration string", key))
+}
+
+// optionTime 读取时间参数，按 loc 时区的挂钟时间解释
+func optionTime(options map[string]interface{}, key string, loc *time.Location) (time.Time, error) {
+	s, err := optionString(options, key)
+	if err != nil || s == "" {
+		return time.Time{}, err
+	}
+	for _, layout := range []string{time.RFC3339Nano, ParseTimeLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return ResolveWallClock(t, loc), nil
+		}
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("trigger option %q: invalid time %q", key, s))
+}
@@ -0,0 +1,323 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// 工作流边的触发条件
+const (
+	// EdgeSuccess 上游节点执行成功后执行下游节点(默认)
+	EdgeSuccess = "success"
+	// EdgeFailure 上游节点执行失败后执行下游节点
+	EdgeFailure = "failure"
+	// EdgeAlways 上游节点执行结束(成功或失败)后执行下游节点
+	EdgeAlways = "always"
+)
+
+// 工作流运行及节点状态
+const (
+	WorkflowPending   = "pending"
+	WorkflowRunning   = "running"
+	WorkflowSucceeded = "succeeded"
+	WorkflowFailed    = "failed"
+	// WorkflowSkipped 节点的上游未满足触发条件，不再执行
+	WorkflowSkipped   = "skipped"
+	WorkflowCancelled = "cancelled"
+)
+
+// WorkflowNode 工作流中的一个节点，引用已有任务(JobId)或直接指定要执行的函数及参数
+type WorkflowNode struct {
+	Name     string        `json:"name"`
+	JobId    string        `json:"jobId"`
+	FuncName string        `json:"funcName"`
+	Args     []interface{} `json:"args"`
+}
+
+// WorkflowEdge 节点间的依赖，From 节点达到 Condition 要求的状态后执行 To 节点
+type WorkflowEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Condition string `json:"condition"`
+}
+
+// Workflow 由任务组成的有向无环图
+type Workflow struct {
+	Id    string         `json:"id"`
+	Name  string         `json:"name"`
+	Nodes []WorkflowNode `json:"nodes"`
+	Edges []WorkflowEdge `json:"edges"`
+}
+
+// WorkflowNodeRun 节点在一次工作流运行中的状态
+type WorkflowNodeRun struct {
+	Status    string    `json:"status"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Error     string    `json:"error"`
+}
+
+// WorkflowRun 工作流的一次运行
+type WorkflowRun struct {
+	Id         string                      `json:"id"`
+	WorkflowId string                      `json:"workflowId"`
+	Status     string                      `json:"status"`
+	StartTime  time.Time                   `json:"startTime"`
+	EndTime    time.Time                   `json:"endTime"`
+	Nodes      map[string]*WorkflowNodeRun `json:"nodes"`
+}
+
+// Init 校验工作流并生成 id
+func (workflow *Workflow) Init() error {
+	if err := workflow.Validate(); err != nil {
+		return err
+	}
+	workflow.Id = uuid.New().String()
+	return nil
+}
+
+// Validate 校验节点、边的定义，并检查是否存在环
+func (workflow *Workflow) Validate() error {
+	if len(workflow.Nodes) == 0 {
+		return errors.New("workflow requires at least one node")
+	}
+	nodes := make(map[string]bool)
+	for _, node := range workflow.Nodes {
+		if strings.TrimSpace(node.Name) == "" {
+			return errors.New("workflow node name must not be empty")
+		}
+		if nodes[node.Name] {
+			return errors.New(fmt.Sprintf("duplicate workflow node %s", node.Name))
+		}
+		if node.JobId == "" && node.FuncName == "" {
+			return errors.New(fmt.Sprintf("workflow node %s requires a jobId or funcName", node.Name))
+		}
+		nodes[node.Name] = true
+	}
+	for _, edge := range workflow.Edges {
+		if !nodes[edge.From] || !nodes[edge.To] {
+			return errors.New(fmt.Sprintf("workflow edge %s -> %s references an unknown node", edge.From, edge.To))
+		}
+		if !validEdgeCondition(edge.Condition) {
+			return errors.New(fmt.Sprintf("invalid workflow edge condition %q", edge.Condition))
+		}
+	}
+	if cycle := workflow.findCycle(); cycle != nil {
+		return errors.New(fmt.Sprintf("workflow has a cycle: %s", strings.Join(cycle, " -> ")))
+	}
+	return nil
+}
+
+func validEdgeCondition(condition string) bool {
+	return condition == "" || condition == EdgeSuccess || condition == EdgeFailure || condition == EdgeAlways
+}
+
+// findCycle 深度优先搜索，返回找到的第一个环上的节点，无环时返回 nil
+func (workflow *Workflow) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		path = append(path, name)
+		for _, edge := range workflow.Edges {
+			if edge.From != name {
+				continue
+			}
+			switch state[edge.To] {
+			case visiting:
+				for i, n := range path {
+					if n == edge.To {
+						return append(append([]string{}, path[i:]...), edge.To)
+					}
+				}
+			case unvisited:
+				if cycle := visit(edge.To); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, node := range workflow.Nodes {
+		if state[node.Name] == unvisited {
+			if cycle := visit(node.Name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Node 按名称查找节点
+func (workflow *Workflow) Node(name string) (WorkflowNode, bool) {
+	for _, node := range workflow.Nodes {
+		if node.Name == name {
+			return node, true
+		}
+	}
+	return WorkflowNode{}, false
+}
+
+// NewRun 创建工作流的一次运行，所有节点处于 pending 状态
+func (workflow *Workflow) NewRun() *WorkflowRun {
+	run := &WorkflowRun{
+		Id:         uuid.New().String(),
+		WorkflowId: workflow.Id,
+		Status:     WorkflowRunning,
+		StartTime:  time.Now(),
+		Nodes:      make(map[string]*WorkflowNodeRun),
+	}
+	for _, node := range workflow.Nodes {
+		run.Nodes[node.Name] = &WorkflowNodeRun{Status: WorkflowPending}
+	}
+	return run
+}
+
+// Finished 运行是否已结束
+func (run *WorkflowRun) Finished() bool {
+	return run.Status != WorkflowRunning
+}
+
+// Ready 返回可以开始执行的节点并将其标记为 running；上游已结束但不满足条件的节点标记为 skipped。
+// 所有节点结束后按是否有失败的节点设置运行状态
+func (run *WorkflowRun) Ready(workflow *Workflow) []string {
+	if run.Finished() {
+		return nil
+	}
+	var ready []string
+	// 跳过节点可能导致其下游也被跳过，循环直到状态不再变化
+	for changed := true; changed; {
+		changed = false
+		for _, node := range workflow.Nodes {
+			nodeRun := run.Nodes[node.Name]
+			if nodeRun.Status != WorkflowPending {
+				continue
+			}
+			satisfied, resolved := run.upstreamState(workflow, node.Name)
+			if !resolved {
+				continue
+			}
+			if satisfied {
+				nodeRun.Status = WorkflowRunning
+				nodeRun.StartTime = time.Now()
+				ready = append(ready, node.Name)
+			} else {
+				nodeRun.Status = WorkflowSkipped
+				nodeRun.EndTime = time.Now()
+				changed = true
+			}
+		}
+	}
+	run.updateStatus()
+	return ready
+}
+
+// upstreamState 检查节点的所有上游: resolved 表示上游均已结束，satisfied 表示所有入边条件均满足
+func (run *WorkflowRun) upstreamState(workflow *Workflow, name string) (satisfied, resolved bool) {
+	satisfied = true
+	for _, edge := range workflow.Edges {
+		if edge.To != name {
+			continue
+		}
+		switch run.Nodes[edge.From].Status {
+		case WorkflowPending, WorkflowRunning:
+			return false, false
+		case WorkflowSucceeded:
+			satisfied = satisfied && edge.Condition != EdgeFailure
+		case WorkflowFailed:
+			satisfied = satisfied && (edge.Condition == EdgeFailure || edge.Condition == EdgeAlways)
+		default:
+			// 上游被跳过或取消，下游不再执行
+			satisfied = false
+		}
+	}
+	return satisfied, true
+}
+
+// Complete 记录节点的执行结果
+func (run *WorkflowRun) Complete(name string, err error) {
+	nodeRun, ok := run.Nodes[name]
+	if !ok || nodeRun.Status != WorkflowRunning {
+		return
+	}
+	nodeRun.EndTime = time.Now()
+	if err != nil {
+		nodeRun.Status = WorkflowFailed
+		nodeRun.Error = err.Error()
+	} else {
+		nodeRun.Status = WorkflowSucceeded
+	}
+}
+
+// Cancel 取消运行，尚未开始的节点不再执行
+func (run *WorkflowRun) Cancel() {
+	if run.Finished() {
+		return
+	}
+	for _, nodeRun := range run.Nodes {
+		if nodeRun.Status == WorkflowPending {
+			nodeRun.Status = WorkflowCancelled
+			nodeRun.EndTime = time.Now()
+		}
+	}
+	run.Status = WorkflowCancelled
+	run.EndTime = time.Now()
+}
+
+func (run *WorkflowRun) updateStatus() {
+	failed := false
+	for _, nodeRun := range run.Nodes {
+		switch nodeRun.Status {
+		case WorkflowPending, WorkflowRunning:
+			return
+		case WorkflowFailed:
+			failed = true
+		}
+	}
+	run.Status = WorkflowSucceeded
+	if failed {
+		run.Status = WorkflowFailed
+	}
+	run.EndTime = time.Now()
+}
+
+func (workflow *Workflow) Bytes() []byte {
+	// 使用 encoding/gob 序列化
+	buf := new(bytes.Buffer)
+	_ = gob.NewEncoder(buf).Encode(workflow)
+	return buf.Bytes()
+}
+
+func BytesToWorkflow(b []byte) *Workflow {
+	// 使用 encoding/gob 反序列化
+	var workflow Workflow
+	_ = gob.NewDecoder(bytes.NewBuffer(b)).Decode(&workflow)
+	return &workflow
+}
+
+func (run *WorkflowRun) Bytes() []byte {
+	// 使用 encoding/gob 序列化
+	buf := new(bytes.Buffer)
+	_ = gob.NewEncoder(buf).Encode(run)
+	return buf.Bytes()
+}
+
+func BytesToWorkflowRun(b []byte) *WorkflowRun {
+	// 使用 encoding/gob 反序列化
+	var run WorkflowRun
+	_ = gob.NewDecoder(bytes.NewBuffer(b)).Decode(&run)
+	return &run
+}
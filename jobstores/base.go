@@ -4,6 +4,7 @@ import (
 	"go-Job-Scheduler/jobs"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type JobStore interface {
@@ -13,7 +14,24 @@ type JobStore interface {
 	UpdateJob(*jobs.Job, jobs.Job) error
 	GetJobById(string) *jobs.Job
 	GetJobs2Run() []jobs.Job
+	GetNextRunTime() (time.Time, bool)
 	GetAllJobs() []jobs.Job
+	AddCalendar(jobs.Calendar) error
+	UpdateCalendar(jobs.Calendar) error
+	RemoveCalendar(string) error
+	GetCalendar(string) *jobs.Calendar
+	GetAllCalendars() []jobs.Calendar
+	AddWorkflow(*jobs.Workflow) error
+	RemoveWorkflow(string) error
+	GetWorkflow(string) *jobs.Workflow
+	GetAllWorkflows() []jobs.Workflow
+	SaveWorkflowRun(jobs.WorkflowRun) error
+	GetWorkflowRun(string) *jobs.WorkflowRun
+	GetWorkflowRuns(string) []jobs.WorkflowRun
+	AddDeadLetter(jobs.DeadLetter) error
+	RemoveDeadLetter(string) error
+	GetDeadLetter(string) *jobs.DeadLetter
+	GetDeadLetters(string) []jobs.DeadLetter
 	sync.Locker
 }
 
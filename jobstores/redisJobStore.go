@@ -13,25 +13,39 @@ import (
 )
 
 const (
-	RedisKey    = "job::store"
-	RuntimesKey = "job::runtimes"
+	RedisKey     = "job::store"
+	RuntimesKey  = "job::runtimes"
+	CalendarsKey = "job::calendars"
+	WorkflowsKey = "job::workflows"
+	// WorkflowRunsKey 工作流运行记录
+	WorkflowRunsKey = "job::workflow_runs"
+	// DeadLettersKey 重试次数用尽后仍失败的执行
+	DeadLettersKey = "job::deadletters"
 )
 
 type RedisJobStore struct {
-	storeKey    string
-	runtimesKey string
-	Host        string
-	Port        int
-	DB          int
-	password    string
-	Client      *redis.Client
+	storeKey        string
+	runtimesKey     string
+	calendarsKey    string
+	workflowsKey    string
+	workflowRunsKey string
+	deadLettersKey  string
+	Host            string
+	Port            int
+	DB              int
+	password        string
+	Client          *redis.Client
 	sync.RWMutex
 }
 
 func newRedisJobStore() JobStore {
 	return &RedisJobStore{
-		storeKey:    RedisKey,
-		runtimesKey: RuntimesKey,
+		storeKey:        RedisKey,
+		runtimesKey:     RuntimesKey,
+		calendarsKey:    CalendarsKey,
+		workflowsKey:    WorkflowsKey,
+		workflowRunsKey: WorkflowRunsKey,
+		deadLettersKey:  DeadLettersKey,
 	}
 }
 
@@ -51,6 +65,26 @@ func (store *RedisJobStore) setOption(option StoreOption) {
 	if err != nil {
 		panic(err)
 	}
+	store.reindex()
+}
+
+// reindex 按任务的下次执行时间重建有序集合，兼容以秒为分值保存的旧数据
+func (store *RedisJobStore) reindex() {
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	pipe := store.Client.Pipeline()
+	pipe.Del(store.runtimesKey)
+	for _, job := range store.GetAllJobs() {
+		if job.NextRunTime() != 0 {
+			pipe.ZAdd(store.runtimesKey, redis.Z{Score: job.NextRunTime(), Member: job.Id})
+		}
+	}
+	_, err := pipe.Exec()
+	if err != nil {
+		log.Println("Error: RedisJobStore::reindex,", err)
+	}
 }
 
 func (store *RedisJobStore) connect() {
@@ -66,17 +100,27 @@ func (store *RedisJobStore) connect() {
 
 func (store *RedisJobStore) AddJob(j jobs.Job) error {
 	if store.Client.HExists(store.storeKey, j.Id).Val() {
-		return errors.New(fmt.Sprint("job %s already exists", j.Id))
+		return errors.New(fmt.Sprintf("job %s already exists", j.Id))
 	}
-	var job *jobs.Job
-	// 如果传入的job id为空， 则调用jobs.New生成job id
+	job := &j
+	// 如果传入的job id为空， 则调用job.Init生成job id并计算首次执行时间
 	if strings.EqualFold(j.Id, "") {
-		job = jobs.New(j.Name, j.FuncName, j.StartTime, j.Interval, j.Type, j.Args...)
-	} else {
-		job = &j
+		if err := job.Init(); err != nil {
+			return errors.New(fmt.Sprintf("Error: RedisJobStore::AddJob, %s", err.Error()))
+		}
+		// 按任务关联的日历调整首次执行时间
+		if job.Calendar != "" {
+			calendar := store.GetCalendar(job.Calendar)
+			if calendar == nil {
+				return errors.New(fmt.Sprintf("Error: RedisJobStore::AddJob, no such calendar %s", job.Calendar))
+			}
+			if err := job.ApplyCalendar(calendar); err != nil {
+				return errors.New(fmt.Sprintf("Error: RedisJobStore::AddJob, %s", err.Error()))
+			}
+		}
+		job.ApplyJitter()
 	}
 
-	log.Println("ExecutionPeriodic=", jobs.ExecutionPeriodic)
 	// 加锁
 	store.Lock()
 	// 函数执行完毕前解锁
@@ -117,7 +161,69 @@ func (store *RedisJobStore) UpdateJob(job *jobs.Job, anotherJob jobs.Job) error
 	// 函数执行完毕前解锁
 	defer store.Unlock()
 	err := job.Update(anotherJob)
-	return err
+	if err != nil {
+		return err
+	}
+	// 修改了执行时间相关的设置时，从当前时间起重新计算下次执行时间
+	if anotherJob.ModifiesSchedule() && !job.Finished() {
+		var calendar *jobs.Calendar
+		if job.Calendar != "" {
+			calendar = store.GetCalendar(job.Calendar)
+			if calendar == nil {
+				return errors.New(fmt.Sprintf("Error: RedisJobStore::UpdateJob, no such calendar %s", job.Calendar))
+			}
+		}
+		if err = reschedule(job, calendar); err != nil {
+			return err
+		}
+	}
+	// 保存修改后的任务
+	pipe := store.Client.Pipeline()
+	store.saveJob(pipe, job)
+	_, err = pipe.Exec()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::UpdateJob, %s", err.Error()))
+	}
+	return nil
+}
+
+// reschedule 从当前时间起重新计算任务的下次执行时间，并按日历跳过或顺延被排除的时间
+func reschedule(job *jobs.Job, calendar *jobs.Calendar) error {
+	if err := job.Reschedule(time.Now()); err != nil {
+		return err
+	}
+	if err := job.ApplyCalendar(calendar); err != nil {
+		return err
+	}
+	if job.ReachedEnd() {
+		job.Finish()
+	}
+	job.ApplyJitter()
+	return nil
+}
+
+// saveJob 在 pipeline 中保存任务及其下次执行时间，下次执行时间为0时不再调度
+func (store *RedisJobStore) saveJob(pipe redis.Pipeliner, job *jobs.Job) {
+	pipe.HSet(store.storeKey, job.Id, job.Bytes())
+	if job.NextRunTime() != 0 {
+		pipe.ZAdd(store.runtimesKey, redis.Z{Score: job.NextRunTime(), Member: job.Id})
+	} else {
+		pipe.ZRem(store.runtimesKey, job.Id)
+	}
+}
+
+func (store *RedisJobStore) GetNextRunTime() (time.Time, bool) {
+	// 分值最小的任务即为最早执行的任务
+	results := store.Client.ZRangeByScoreWithScores(store.runtimesKey, redis.ZRangeBy{
+		Min:   "1",
+		Max:   "+inf",
+		Count: 1,
+	}).Val()
+	if len(results) == 0 {
+		return time.Time{}, false
+	}
+	ms := int64(results[0].Score)
+	return time.Unix(0, ms*int64(time.Millisecond)), true
 }
 
 func (store *RedisJobStore) GetJobById(id string) *jobs.Job {
@@ -127,7 +233,8 @@ func (store *RedisJobStore) GetJobById(id string) *jobs.Job {
 
 func (store *RedisJobStore) GetJobs2Run() []jobs.Job {
 	var jobs2Run []jobs.Job
-	now := time.Now().Unix()
+	// 分值为毫秒时间戳
+	now := time.Now().UnixNano() / int64(time.Millisecond)
 	// 加锁
 	store.Lock()
 	// 函数执行完毕前解锁
@@ -136,7 +243,7 @@ func (store *RedisJobStore) GetJobs2Run() []jobs.Job {
 	// 从 redis 中按 Score 获取从0到当前时间戳之间的所有任务id
 	results := store.Client.ZRangeByScore(store.runtimesKey, redis.ZRangeBy{
 		Min: "1",
-		Max: strconv.Itoa(int(now)),
+		Max: strconv.FormatInt(now, 10),
 	}).Val()
 
 	if len(results) <= 0 {
@@ -174,3 +281,259 @@ func (store *RedisJobStore) GetAllJobs() []jobs.Job {
 	}
 	return allJobs
 }
+
+func (store *RedisJobStore) AddCalendar(calendar jobs.Calendar) error {
+	if err := calendar.Validate(); err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::AddCalendar, %s", err.Error()))
+	}
+	// 加锁，检查与保存之间不会有其他同名日历被添加
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	if store.Client.HExists(store.calendarsKey, calendar.Name).Val() {
+		return errors.New(fmt.Sprintf("calendar %s already exists", calendar.Name))
+	}
+	return store.saveCalendar(calendar)
+}
+
+// UpdateCalendar 替换同名日历，并按新日历重新计算使用该日历的任务的下次执行时间
+func (store *RedisJobStore) UpdateCalendar(calendar jobs.Calendar) error {
+	if err := calendar.Validate(); err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::UpdateCalendar, %s", err.Error()))
+	}
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	if !store.Client.HExists(store.calendarsKey, calendar.Name).Val() {
+		return errors.New(fmt.Sprintf("no such calendar %s", calendar.Name))
+	}
+	if err := store.saveCalendar(calendar); err != nil {
+		return err
+	}
+
+	// 已保存的下次执行时间可能落在新排除的日期或时间段内
+	now := time.Now()
+	pipe := store.Client.Pipeline()
+	for _, job := range store.GetAllJobs() {
+		// 即将执行的任务不再调整
+		if job.Calendar != calendar.Name || job.Finished() || (job.NextRunTime() != 0 && !job.ScheduledTime().After(now)) {
+			continue
+		}
+		if err := reschedule(&job, &calendar); err != nil {
+			log.Println("Error: RedisJobStore::UpdateCalendar,", job.Id, err)
+		}
+		store.saveJob(pipe, &job)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::UpdateCalendar, %s", err.Error()))
+	}
+	return nil
+}
+
+// saveCalendar 保存日历，调用前需已加锁
+func (store *RedisJobStore) saveCalendar(calendar jobs.Calendar) error {
+	err := store.Client.HSet(store.calendarsKey, calendar.Name, calendar.Bytes()).Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::saveCalendar, %s", err.Error()))
+	}
+	return nil
+}
+
+func (store *RedisJobStore) RemoveCalendar(name string) error {
+	// 仍被任务引用的日历不允许删除
+	for _, job := range store.GetAllJobs() {
+		if job.Calendar == name {
+			return errors.New(fmt.Sprintf("calendar %s is used by job %s", name, job.Id))
+		}
+	}
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	err := store.Client.HDel(store.calendarsKey, name).Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::RemoveCalendar, %s", err.Error()))
+	}
+	return nil
+}
+
+func (store *RedisJobStore) GetCalendar(name string) *jobs.Calendar {
+	val, err := store.Client.HGet(store.calendarsKey, name).Result()
+	if err != nil {
+		return nil
+	}
+	return jobs.BytesToCalendar([]byte(val))
+}
+
+func (store *RedisJobStore) GetAllCalendars() []jobs.Calendar {
+	var calendars []jobs.Calendar
+	results, err := store.Client.HGetAll(store.calendarsKey).Result()
+	if err != nil {
+		log.Println("Error: redisStore GetAllCalendars, ", err)
+	}
+	for _, serialized := range results {
+		calendar := jobs.BytesToCalendar([]byte(serialized))
+		if calendar != nil {
+			calendars = append(calendars, *calendar)
+		}
+	}
+	return calendars
+}
+
+func (store *RedisJobStore) AddWorkflow(workflow *jobs.Workflow) error {
+	// 如果传入的工作流 id 为空，则调用 workflow.Init 校验并生成 id
+	if strings.EqualFold(workflow.Id, "") {
+		if err := workflow.Init(); err != nil {
+			return errors.New(fmt.Sprintf("Error: RedisJobStore::AddWorkflow, %s", err.Error()))
+		}
+	} else if err := workflow.Validate(); err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::AddWorkflow, %s", err.Error()))
+	}
+	// 节点引用的任务必须存在
+	for _, node := range workflow.Nodes {
+		if node.JobId != "" && store.GetJobById(node.JobId).Id == "" {
+			return errors.New(fmt.Sprintf("Error: RedisJobStore::AddWorkflow, node %s: no such job %s", node.Name, node.JobId))
+		}
+	}
+	if store.Client.HExists(store.workflowsKey, workflow.Id).Val() {
+		return errors.New(fmt.Sprintf("workflow %s already exists", workflow.Id))
+	}
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	err := store.Client.HSet(store.workflowsKey, workflow.Id, workflow.Bytes()).Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::AddWorkflow, %s", err.Error()))
+	}
+	return nil
+}
+
+func (store *RedisJobStore) RemoveWorkflow(id string) error {
+	// 有正在运行的工作流时不允许删除
+	for _, run := range store.GetWorkflowRuns(id) {
+		if !run.Finished() {
+			return errors.New(fmt.Sprintf("workflow %s has a running run %s", id, run.Id))
+		}
+	}
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	err := store.Client.HDel(store.workflowsKey, id).Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::RemoveWorkflow, %s", err.Error()))
+	}
+	return nil
+}
+
+func (store *RedisJobStore) GetWorkflow(id string) *jobs.Workflow {
+	val, err := store.Client.HGet(store.workflowsKey, id).Result()
+	if err != nil {
+		return nil
+	}
+	return jobs.BytesToWorkflow([]byte(val))
+}
+
+func (store *RedisJobStore) GetAllWorkflows() []jobs.Workflow {
+	var workflows []jobs.Workflow
+	results, err := store.Client.HGetAll(store.workflowsKey).Result()
+	if err != nil {
+		log.Println("Error: redisStore GetAllWorkflows, ", err)
+	}
+	for _, serialized := range results {
+		workflow := jobs.BytesToWorkflow([]byte(serialized))
+		if workflow != nil {
+			workflows = append(workflows, *workflow)
+		}
+	}
+	return workflows
+}
+
+func (store *RedisJobStore) SaveWorkflowRun(run jobs.WorkflowRun) error {
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	err := store.Client.HSet(store.workflowRunsKey, run.Id, run.Bytes()).Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::SaveWorkflowRun, %s", err.Error()))
+	}
+	return nil
+}
+
+func (store *RedisJobStore) GetWorkflowRun(id string) *jobs.WorkflowRun {
+	val, err := store.Client.HGet(store.workflowRunsKey, id).Result()
+	if err != nil {
+		return nil
+	}
+	return jobs.BytesToWorkflowRun([]byte(val))
+}
+
+// GetWorkflowRuns 返回工作流的所有运行记录，workflowId 为空时返回所有工作流的运行记录
+func (store *RedisJobStore) GetWorkflowRuns(workflowId string) []jobs.WorkflowRun {
+	var runs []jobs.WorkflowRun
+	results, err := store.Client.HGetAll(store.workflowRunsKey).Result()
+	if err != nil {
+		log.Println("Error: redisStore GetWorkflowRuns, ", err)
+	}
+	for _, serialized := range results {
+		run := jobs.BytesToWorkflowRun([]byte(serialized))
+		if run != nil && (workflowId == "" || run.WorkflowId == workflowId) {
+			runs = append(runs, *run)
+		}
+	}
+	return runs
+}
+
+func (store *RedisJobStore) AddDeadLetter(deadLetter jobs.DeadLetter) error {
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	err := store.Client.HSet(store.deadLettersKey, deadLetter.Id, deadLetter.Bytes()).Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::AddDeadLetter, %s", err.Error()))
+	}
+	return nil
+}
+
+func (store *RedisJobStore) RemoveDeadLetter(id string) error {
+	// 加锁
+	store.Lock()
+	// 函数执行完毕前解锁
+	defer store.Unlock()
+	removed, err := store.Client.HDel(store.deadLettersKey, id).Result()
+	if err != nil {
+		return errors.New(fmt.Sprintf("Error: RedisJobStore::RemoveDeadLetter, %s", err.Error()))
+	}
+	if removed == 0 {
+		return errors.New(fmt.Sprintf("no such dead letter %s", id))
+	}
+	return nil
+}
+
+func (store *RedisJobStore) GetDeadLetter(id string) *jobs.DeadLetter {
+	val, err := store.Client.HGet(store.deadLettersKey, id).Result()
+	if err != nil {
+		return nil
+	}
+	return jobs.BytesToDeadLetter([]byte(val))
+}
+
+// GetDeadLetters 返回任务的所有死信，jobId 为空时返回所有任务的死信
+func (store *RedisJobStore) GetDeadLetters(jobId string) []jobs.DeadLetter {
+	var deadLetters []jobs.DeadLetter
+	results, err := store.Client.HGetAll(store.deadLettersKey).Result()
+	if err != nil {
+		log.Println("Error: redisStore GetDeadLetters, ", err)
+	}
+	for _, serialized := range results {
+		deadLetter := jobs.BytesToDeadLetter([]byte(serialized))
+		if deadLetter != nil && (jobId == "" || deadLetter.Job.OriginalId() == jobId) {
+			deadLetters = append(deadLetters, *deadLetter)
+		}
+	}
+	return deadLetters
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"go-Job-Scheduler/api"
+	"go-Job-Scheduler/executors"
+	"go-Job-Scheduler/jobs"
 	"go-Job-Scheduler/schedulers"
 	"log"
+	"os"
+	"os/signal"
 	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func init() {
@@ -15,6 +26,11 @@ func init() {
 func main() {
 	// 设置 goroutine 最大运行并发数
 	runtime.GOMAXPROCS(runtime.NumCPU()*2 + 1)
+	// worker 子命令：连接调度器的远程执行器执行任务
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		runWorker(os.Args[2:])
+		return
+	}
 	// 解析启动参数
 	var host string
 	var port int
@@ -31,6 +47,18 @@ func main() {
 
 	var executorType string
 	var executorPoolSize int
+	var executorQueueSize int
+	var executorBackpressure string
+	var executorRunLogSize int
+	var executorLeaseTimeout int64
+	var executorQueueLimit string
+	var shellAllow string
+	var shellEnvAllow string
+	var shellDirAllow string
+
+	var historyType string
+	var historyMaxRuns int
+	var historyMaxAge int64
 	flag.StringVar(&host, "h", "127.0.0.1", "-h, listening at 127.0.0.1 by default")
 	flag.IntVar(&port, "p", 10028, "-p, listening at port 10027 by default")
 	flag.Int64Var(&readTimeout, "rt", 5, "--rt, read timeout, default 5 seconds")
@@ -45,9 +73,26 @@ func main() {
 	flag.StringVar(&storeCharset, "store-charset", "", "--store-charset")
 
 	flag.StringVar(&executorType, "executor-type", "base", "--executor-type, job executor type, default is base executor")
-	flag.IntVar(&executorPoolSize, "executor-pool-size", 10, "--executor-pool-size, default is 10")
+	flag.IntVar(&executorPoolSize, "executor-pool-size", 10, "--executor-pool-size, number of workers, default is 10")
+	flag.IntVar(&executorQueueSize, "executor-queue-size", 100, "--executor-queue-size, max runs waiting for a worker, default is 100")
+	flag.IntVar(&executorRunLogSize, "executor-run-log-size", 64*1024, "--executor-run-log-size, max output bytes kept per run, default is 64KiB")
+	flag.Int64Var(&executorLeaseTimeout, "executor-lease-timeout", 30, "--executor-lease-timeout, seconds before a remote run without worker heartbeats is redispatched, default is 30")
+	flag.StringVar(&executorQueueLimit, "executor-queue-limit", "", "--executor-queue-limit, max remote runs leased at the same time per queue, e.g. default=10,gpu=2, unlimited by default")
+	flag.StringVar(&shellAllow, "shell-allow", "", "--shell-allow, comma separated commands shell jobs may run, e.g. /usr/bin/rsync,backup.sh, none by default")
+	flag.StringVar(&shellEnvAllow, "shell-env-allow", "", "--shell-env-allow, comma separated env names shell jobs may set, e.g. RSYNC_RSH, none by default")
+	flag.StringVar(&shellDirAllow, "shell-dir-allow", "", "--shell-dir-allow, comma separated absolute dirs shell jobs may run in, none by default")
+	flag.StringVar(&executorBackpressure, "executor-backpressure", "block", "--executor-backpressure, when the queue is full: block, reject or defer, default is block")
+
+	flag.StringVar(&historyType, "history-type", "redis", "--history-type, run history storage: redis or memory, default is redis, uses the store connection options")
+	flag.IntVar(&historyMaxRuns, "history-max-runs", 100, "--history-max-runs, run records kept per job, 0 for unlimited, default is 100")
+	flag.Int64Var(&historyMaxAge, "history-max-age", 7*24*3600, "--history-max-age, seconds to keep run records, 0 for unlimited, default is 7 days")
 	flag.Parse()
 
+	queueLimits, err := parseQueueLimits(executorQueueLimit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// 初始化 scheduler
 	scheduler := schedulers.NewScheduler(map[string]interface{}{
 		"store": map[string]interface{}{
@@ -64,13 +109,125 @@ func main() {
 		"executor": map[string]interface{}{
 			"type": executorType,
 			"options": map[string]interface{}{
-				"poolSize": executorPoolSize,
+				"poolSize":          executorPoolSize,
+				"queueSize":         executorQueueSize,
+				"backpressure":      executorBackpressure,
+				"runLogSize":        executorRunLogSize,
+				"shellAllowList":    strings.Split(shellAllow, ","),
+				"shellEnvAllowList": strings.Split(shellEnvAllow, ","),
+				"shellDirAllowList": strings.Split(shellDirAllow, ","),
+				"leaseTimeout":      time.Second * time.Duration(executorLeaseTimeout),
+				"queueLimits":       queueLimits,
+			},
+		},
+		"history": map[string]interface{}{
+			"type": historyType,
+			"options": map[string]interface{}{
+				"host":     storeHost,
+				"port":     storePort,
+				"dbname":   storeDBName,
+				"password": storePassword,
+				"maxRuns":  historyMaxRuns,
+				"maxAge":   time.Second * time.Duration(historyMaxAge),
 			},
 		},
 	})
+	if scheduler == nil {
+		log.Fatal("Error: invalid scheduler config")
+	}
 	// 启动goroutine运行
 	go scheduler.Run()
 	// 启动web server
 	server := api.NewWebServer(host, port, readTimeout, writeTimeout)
+	// 收到退出信号时停止接收请求，取消正在运行的任务后退出
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Println("Received", sig, ", shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(writeTimeout))
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
 	server.Start()
+	scheduler.Shutdown()
+	log.Println("Scheduler stopped")
+}
+
+// runWorker 运行 worker 进程，从调度器(--executor-type remote)领取任务执行，收到退出信号时取消正在执行的任务后退出
+func runWorker(args []string) {
+	var schedulerURL string
+	var name string
+	var poolSize int
+	var runLogSize int
+	var shellAllow string
+	var shellEnvAllow string
+	var shellDirAllow string
+	var labels string
+	var queues string
+
+	hostname, _ := os.Hostname()
+	flags := flag.NewFlagSet("worker", flag.ExitOnError)
+	flags.StringVar(&schedulerURL, "scheduler", "http://127.0.0.1:10028", "--scheduler, scheduler web server address")
+	flags.StringVar(&name, "name", hostname, "--name, worker name shown in /api/workers, default is the hostname")
+	flags.IntVar(&poolSize, "pool-size", 10, "--pool-size, number of jobs run at the same time, default is 10")
+	flags.IntVar(&runLogSize, "run-log-size", 64*1024, "--run-log-size, max output bytes kept per run, default is 64KiB")
+	flags.StringVar(&shellAllow, "shell-allow", "", "--shell-allow, comma separated commands shell jobs may run, shell jobs are not taken by default")
+	flags.StringVar(&shellEnvAllow, "shell-env-allow", "", "--shell-env-allow, comma separated env names shell jobs may set, none by default")
+	flags.StringVar(&shellDirAllow, "shell-dir-allow", "", "--shell-dir-allow, comma separated absolute dirs shell jobs may run in, none by default")
+	flags.StringVar(&labels, "labels", "", "--labels, worker labels matched against job labels, e.g. region=eu,gpu=false")
+	flags.StringVar(&queues, "queues", "", "--queues, comma separated queues to take jobs from, all queues by default")
+	_ = flags.Parse(args)
+
+	workerLabels, err := jobs.ParseLabels(labels)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var workerQueues []string
+	for _, queue := range strings.Split(queues, ",") {
+		if queue = strings.TrimSpace(queue); queue != "" {
+			workerQueues = append(workerQueues, queue)
+		}
+	}
+
+	worker := executors.NewWorker(executors.WorkerOption{
+		SchedulerURL:      schedulerURL,
+		Name:              name,
+		PoolSize:          poolSize,
+		RunLogSize:        runLogSize,
+		ShellAllowList:    strings.Split(shellAllow, ","),
+		ShellEnvAllowList: strings.Split(shellEnvAllow, ","),
+		ShellDirAllowList: strings.Split(shellDirAllow, ","),
+		Labels:            workerLabels,
+		Queues:            workerQueues,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Println("Received", sig, ", shutting down")
+		cancel()
+	}()
+	if err := worker.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+	log.Println("Worker stopped")
+}
+
+// parseQueueLimits 解析 "default=10,gpu=2" 格式的队列并发上限
+func parseQueueLimits(s string) (map[string]int, error) {
+	pairs, err := jobs.ParseLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	limits := make(map[string]int)
+	for queue, value := range pairs {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, errors.New(fmt.Sprintf("invalid limit %q for queue %s", value, queue))
+		}
+		limits[queue] = limit
+	}
+	return limits, nil
 }
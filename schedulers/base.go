@@ -1,19 +1,49 @@
 package schedulers
 
 import (
+	"errors"
+	"fmt"
 	"go-Job-Scheduler/executors"
+	"go-Job-Scheduler/histories"
 	"go-Job-Scheduler/jobs"
 	"go-Job-Scheduler/jobstores"
+	"log"
 	"sync"
 	"time"
 )
 
 var scheduler *baseScheduler
 
+// DeferDelay 执行队列已满(backpressure 为 block 或 defer)时，任务放回 store 后重新提交的延迟
+const DeferDelay = 100 * time.Millisecond
+
 type baseScheduler struct {
 	running  bool
 	JobStore jobstores.JobStore
 	Executor executors.Executor
+	// History 执行记录存储，未配置时不记录
+	History histories.History
+	// wakeup 任务增删改后唤醒调度循环，重新计算等待时间
+	wakeup chan struct{}
+}
+
+// componentConfig 读取 store、executor、history 配置中的 type 及 options，options 可省略
+func componentConfig(m map[string]interface{}, name string) (string, map[string]interface{}, error) {
+	v, ok := m[name].(map[string]interface{})
+	if !ok {
+		return "", nil, errors.New(fmt.Sprintf("%s config must be an object", name))
+	}
+	typeStr, ok := v["type"].(string)
+	if !ok {
+		return "", nil, errors.New(fmt.Sprintf("%s config requires a string type", name))
+	}
+	options := map[string]interface{}{}
+	if raw, exists := v["options"]; exists {
+		if options, ok = raw.(map[string]interface{}); !ok {
+			return "", nil, errors.New(fmt.Sprintf("%s options must be an object", name))
+		}
+	}
+	return typeStr, options, nil
 }
 
 func NewScheduler(m map[string]interface{}) *baseScheduler {
@@ -23,29 +53,48 @@ func NewScheduler(m map[string]interface{}) *baseScheduler {
 	s := GetScheduler()
 
 	// 配置 job store
-	storeConfig := m["store"]
-	if v, ok := storeConfig.(map[string]interface{}); ok {
-		storeType := v["type"].(string)
-		optionsMap := v["options"].(map[string]interface{})
-		options := jobstores.MapToStoreOption(optionsMap)
-		jobStore = jobstores.NewJobStore(storeType, options)
-	} else {
+	storeType, optionsMap, err := componentConfig(m, "store")
+	if err != nil {
+		log.Println("Error:", err)
+		return nil
+	}
+	jobStore = jobstores.NewJobStore(storeType, jobstores.MapToStoreOption(optionsMap))
+	if jobStore == nil {
+		log.Println("Error: unknown store type", storeType)
 		return nil
 	}
 
 	// 配置 job executor
-	executorConfig := m["executor"]
-	if v, ok := executorConfig.(map[string]interface{}); ok {
-		executorType := v["type"].(string)
-		optionsMap := v["options"].(map[string]interface{})
-		options := executors.MapToExecutorOption(optionsMap)
-		executor = executors.NewExecutor(executorType, options)
-	} else {
+	executorType, optionsMap, err := componentConfig(m, "executor")
+	if err != nil {
+		log.Println("Error:", err)
+		return nil
+	}
+	executor = executors.NewExecutor(executorType, executors.MapToExecutorOption(optionsMap))
+	if executor == nil {
+		log.Println("Error: unknown executor type", executorType)
 		return nil
 	}
+	// 配置执行记录存储，未配置或配置有误时不保存执行记录
+	if _, ok := m["history"]; ok {
+		historyType, optionsMap, err := componentConfig(m, "history")
+		if err == nil {
+			s.History, err = histories.NewHistory(historyType, histories.MapToHistoryOption(optionsMap))
+		}
+		if err != nil {
+			log.Println("Error:", err)
+		}
+	}
+
 	s.JobStore = jobStore
 	s.running = true
 	s.Executor = executor
+	// 工作流节点执行结束后触发下游节点
+	executors.AddResultListener(s.onWorkflowResult)
+	// 执行失败时按重试策略重试
+	executors.AddResultListener(s.onRetryResult)
+	// 保存执行记录
+	executors.AddResultListener(s.onHistoryResult)
 
 	return s
 }
@@ -58,36 +107,188 @@ func (this *baseScheduler) IsRunning() bool {
 	return this.running
 }
 
+// onHistoryResult 保存任务的执行记录
+func (this *baseScheduler) onHistoryResult(result executors.Result) {
+	if this.History == nil {
+		return
+	}
+	if err := this.History.Add(result.Record()); err != nil {
+		log.Println("Error:", result.Job.Id, err)
+	}
+}
+
+// Shutdown 停止调度，取消所有正在运行的任务并等待其返回
+func (this *baseScheduler) Shutdown() {
+	this.running = false
+	this.Executor.Shutdown()
+}
+
+// Wakeup 唤醒调度循环，任务被添加、修改或删除后调用
+func (this *baseScheduler) Wakeup() {
+	select {
+	case this.wakeup <- struct{}{}:
+	default:
+		// 已有未处理的唤醒信号
+	}
+}
+
 func (this *baseScheduler) Run() {
-	// 以秒为单位的ticker
-	var ticker = time.NewTicker(time.Second * 1)
+	// 继续上次停止时未结束的工作流运行
+	this.resumeWorkflowRuns()
+
+	// 不轮询 store，而是等待到最早的任务执行时间，或被 Wakeup 唤醒
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			// 调度
-			if this.running == true {
-				// 获取到期应执行任务
-				jobs2Run := this.JobStore.GetJobs2Run()
-				// 遍历
-				for _, job := range jobs2Run {
-					// 将任务交给executor
-					this.Executor.Add(job)
-					// 如果为周期性任务
-					if job.Type == jobs.ExecutionPeriodic {
-						// 修改任务的下次执行时间
-						job.NextRunTime_ = job.NextRunTime_.Add(job.Interval)
-					} else {
-						// 一次性任务则将下次执行时间设置为0
-						job.NextRunTime_ = time.Unix(0, 0)
-					}
-					// 将任务放回 store
-					_ = this.JobStore.AddJob(job)
+		case <-timer.C:
+		case <-this.wakeup:
+		}
+		// 调度
+		if this.running == true {
+			// 获取到期应执行任务
+			jobs2Run := this.JobStore.GetJobs2Run()
+			// 遍历
+			for _, job := range jobs2Run {
+				// 提交执行并计算下次执行时间
+				this.schedule(&job, time.Now())
+				// 重试任务及工作流节点只执行一次，执行后不再保留
+				if job.OneShot() && job.Finished() {
+					continue
 				}
-				// 另起一个 goroutine 执行 executor
-				go this.Executor.Execute()
+				// 将任务放回 store
+				if err := this.JobStore.AddJob(job); err != nil {
+					log.Println("Error:", job.Id, err)
+				}
+			}
+		}
+		// 重置 timer，没有待执行任务时只等待唤醒
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next, ok := this.JobStore.GetNextRunTime(); ok {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// schedule 按任务的错过执行策略将到期任务交给 executor，并计算下次执行时间
+func (this *baseScheduler) schedule(job *jobs.Job, now time.Time) {
+	misfired := job.Misfired(now)
+	runs := 1
+	if misfired {
+		switch job.MisfirePolicy {
+		case jobs.MisfireSkipToNext:
+			runs = 0
+		case jobs.MisfireFireAllMissed:
+			runs = this.countMissedRuns(*job, now)
+			// 合并错过的多次执行为一次
+			if job.Coalesce && runs > 1 {
+				runs = 1
+			}
+		}
+		log.Println("Misfire:", job.Id, "late by", now.Sub(job.NextRunTime_), "policy", job.MisfirePolicy, "runs", runs)
+	}
+
+	submitted := 0
+	for i := 0; i < runs && !(job.MaxRuns > 0 && job.Runs >= job.MaxRuns); i++ {
+		// 将任务交给executor。到期任务已从 store 中取出，阻塞期间无法删除或修改，
+		// 因此不阻塞等待，队列已满时放回 store 稍后重新提交
+		err := this.Executor.TryAdd(*job)
+		if err == executors.ErrDeferred {
+			if submitted == 0 {
+				// 执行队列已满，不计算下次执行时间，稍后重新提交本次执行
+				job.NextRunTime_ = now.Add(DeferDelay)
+				return
 			}
+			log.Println("Deferred:", job.Id, "dropped", runs-submitted, "missed runs, executor queue is full")
+			break
 		}
+		if err != nil {
+			log.Println("Error:", job.Id, err)
+			// 无法提交的工作流节点视为执行失败；调度器关闭时节点保持执行中，重启后重新执行
+			if err != executors.ErrShutdown {
+				this.completeWorkflowNode(*job, err)
+			}
+			continue
+		}
+		submitted++
+		// 累加执行次数
+		job.Runs++
+	}
+
+	// 由任务的触发器计算下次执行时间
+	this.advance(job, now)
+	// 错过执行时直接从当前时间起计算下次执行时间，跳过所有已过去的执行时间，避免之后每次调度都补执行
+	if misfired && job.NextRunTime() != 0 && !job.NextRunTime_.After(now) {
+		this.skipMissed(job, now)
+	}
+	// 触发器不再触发、达到结束时间或最大执行次数时标记为已结束
+	if job.NextRunTime() == 0 || job.ReachedEnd() {
+		job.Finish()
+		return
+	}
+	// 在触发时间上增加随机抖动作为写回 store 的实际执行时间
+	job.ApplyJitter()
+}
+
+// skipMissed 将下次执行时间设置为 now 之后的第一次触发时间
+func (this *baseScheduler) skipMissed(job *jobs.Job, now time.Time) {
+	if err := job.Reschedule(now); err != nil {
+		log.Println("Error:", job.Id, err)
+		job.SetScheduledTime(time.Unix(0, 0))
+		return
+	}
+	this.applyCalendar(job)
+}
+
+// countMissedRuns 统计截至 now 错过的执行次数
+func (this *baseScheduler) countMissedRuns(job jobs.Job, now time.Time) int {
+	runs := 0
+	for job.ScheduledTime().Unix() != 0 && !job.ScheduledTime().After(now) && !job.ReachedEnd() && runs < jobs.MaxMisfireRuns {
+		runs++
+		job.Runs++
+		this.advance(&job, now)
+	}
+	return runs
+}
+
+// advance 计算任务的下次执行时间，并按任务关联的日历跳过或顺延被排除的执行时间
+func (this *baseScheduler) advance(job *jobs.Job, now time.Time) {
+	job.SetScheduledTime(nextRunTime(*job, now))
+	this.applyCalendar(job)
+}
+
+// nextRunTime 计算任务的下次执行时间，触发器无效或不再触发时返回0
+func nextRunTime(job jobs.Job, now time.Time) time.Time {
+	trigger, err := job.NewTrigger()
+	if err != nil {
+		log.Println("Error:", job.Id, err)
+		return time.Unix(0, 0)
+	}
+	next, ok := trigger.NextFireTime(job.ScheduledTime(), now)
+	if !ok {
+		return time.Unix(0, 0)
+	}
+	return next
+}
+
+// applyCalendar 按任务关联的日历调整下次执行时间，日历不存在时忽略
+func (this *baseScheduler) applyCalendar(job *jobs.Job) {
+	if job.Calendar == "" {
+		return
+	}
+	calendar := this.JobStore.GetCalendar(job.Calendar)
+	if calendar == nil {
+		log.Println("Error:", job.Id, "no such calendar", job.Calendar)
+		return
+	}
+	if err := job.ApplyCalendar(calendar); err != nil {
+		log.Println("Error:", job.Id, err)
 	}
 }
 
@@ -95,6 +296,8 @@ func init() {
 	// 调度器设置为单例模式
 	var once sync.Once
 	once.Do(func() {
-		scheduler = &baseScheduler{}
+		scheduler = &baseScheduler{
+			wakeup: make(chan struct{}, 1),
+		}
 	})
 }
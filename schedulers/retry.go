@@ -0,0 +1,67 @@
+package schedulers
+
+import (
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/executors"
+	"go-Job-Scheduler/jobs"
+	"log"
+	"time"
+)
+
+// onRetryResult 按任务的重试策略，将失败的执行通过 store 重新调度；
+// 重试次数用尽或错误不可重试时记录为死信。没有重试策略的任务不记录死信，
+// 否则周期执行且持续失败的任务每次触发都会产生一条死信，失败记录见执行历史
+func (this *baseScheduler) onRetryResult(result executors.Result) {
+	job := result.Job
+	// 被取消的执行(任务被删除或调度器关闭)不再重试
+	if result.Err == nil || job.Retry == nil || result.Status == jobs.RunCancelled {
+		return
+	}
+	if retrying(result) {
+		delay := job.Retry.Delay(job.CurrentAttempt())
+		retry := job.NewRetry(time.Now().Add(delay))
+		err := this.JobStore.AddJob(retry)
+		if err == nil {
+			log.Println("Retry:", job.OriginalId(), "attempt", retry.CurrentAttempt(), "in", delay)
+			this.Wakeup()
+			return
+		}
+		log.Println("Error:", job.Id, err)
+		// 无法重试时工作流节点视为执行失败
+		this.completeWorkflowNode(job, result.Err)
+	}
+	if err := this.JobStore.AddDeadLetter(jobs.NewDeadLetter(job, result.Err)); err != nil {
+		log.Println("Error:", job.Id, err)
+	}
+}
+
+// retrying 判断失败的执行是否会按任务的重试策略重试
+func retrying(result executors.Result) bool {
+	job := result.Job
+	if result.Err == nil || job.Retry == nil || result.Status == jobs.RunCancelled {
+		return false
+	}
+	return job.Retry.Retryable(job.CurrentAttempt(), result.Err)
+}
+
+// ReplayDeadLetter 立即重新执行死信中的任务并删除该死信，重新执行失败时按重试策略从头重试
+func (this *baseScheduler) ReplayDeadLetter(id string) (*jobs.Job, error) {
+	deadLetter := this.JobStore.GetDeadLetter(id)
+	if deadLetter == nil {
+		return nil, errors.New(fmt.Sprintf("no such dead letter %s", id))
+	}
+	replay := deadLetter.Job.NewRetry(time.Now())
+	replay.Attempt = 0
+	// 工作流的运行已结束，重新执行不再影响工作流
+	replay.WorkflowRunId = ""
+	replay.WorkflowNode = ""
+	if err := this.JobStore.AddJob(replay); err != nil {
+		return nil, err
+	}
+	if err := this.JobStore.RemoveDeadLetter(id); err != nil {
+		return nil, err
+	}
+	this.Wakeup()
+	return &replay, nil
+}
@@ -0,0 +1,199 @@
+package schedulers
+
+import (
+	"errors"
+	"fmt"
+	"go-Job-Scheduler/executors"
+	"go-Job-Scheduler/jobs"
+	"log"
+	"sync"
+	"time"
+)
+
+// workflowMu 串行化工作流运行状态的读写
+var workflowMu sync.Mutex
+
+// StartWorkflow 开始工作流的一次运行，立即执行没有上游的节点
+func (this *baseScheduler) StartWorkflow(id string) (*jobs.WorkflowRun, error) {
+	workflow := this.JobStore.GetWorkflow(id)
+	if workflow == nil {
+		return nil, errors.New(fmt.Sprintf("no such workflow %s", id))
+	}
+	workflowMu.Lock()
+	defer workflowMu.Unlock()
+
+	run := workflow.NewRun()
+	if err := this.dispatchWorkflow(workflow, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// CancelWorkflowRun 取消工作流运行，正在执行的节点执行完毕，尚未开始的节点不再执行
+func (this *baseScheduler) CancelWorkflowRun(runId string) (*jobs.WorkflowRun, error) {
+	workflowMu.Lock()
+	defer workflowMu.Unlock()
+
+	run := this.JobStore.GetWorkflowRun(runId)
+	if run == nil {
+		return nil, errors.New(fmt.Sprintf("no such workflow run %s", runId))
+	}
+	if run.Finished() {
+		return nil, errors.New(fmt.Sprintf("workflow run %s already %s", runId, run.Status))
+	}
+	run.Cancel()
+	if err := this.JobStore.SaveWorkflowRun(*run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// onWorkflowResult 记录工作流节点的执行结果，并执行满足条件的下游节点
+func (this *baseScheduler) onWorkflowResult(result executors.Result) {
+	// 失败后将重试的节点在重试结束后才记录结果
+	if retrying(result) {
+		return
+	}
+	this.completeWorkflowNode(result.Job, result.Err)
+}
+
+// completeWorkflowNode 记录工作流节点的执行结果，并执行满足条件的下游节点
+func (this *baseScheduler) completeWorkflowNode(job jobs.Job, err error) {
+	if job.WorkflowRunId == "" {
+		return
+	}
+	workflowMu.Lock()
+	defer workflowMu.Unlock()
+
+	run := this.JobStore.GetWorkflowRun(job.WorkflowRunId)
+	if run == nil {
+		log.Println("Error: no such workflow run", job.WorkflowRunId)
+		return
+	}
+	run.Complete(job.WorkflowNode, err)
+	workflow := this.JobStore.GetWorkflow(run.WorkflowId)
+	if workflow == nil {
+		// 工作流已被删除，只记录结果
+		if err := this.JobStore.SaveWorkflowRun(*run); err != nil {
+			log.Println("Error:", run.Id, err)
+		}
+		return
+	}
+	if err := this.dispatchWorkflow(workflow, run); err != nil {
+		log.Println("Error:", run.Id, err)
+	}
+}
+
+// dispatchWorkflow 保存运行状态，并将可以执行的节点作为一次性任务保存到 store，由调度循环提交执行，
+// 调度器重启后节点仍会执行，executor 队列已满时按 backpressure 稍后重新提交
+func (this *baseScheduler) dispatchWorkflow(workflow *jobs.Workflow, run *jobs.WorkflowRun) error {
+	for {
+		ready := run.Ready(workflow)
+		// 先保存运行状态，避免节点执行结束时读到旧状态
+		if err := this.JobStore.SaveWorkflowRun(*run); err != nil {
+			return err
+		}
+		if len(ready) == 0 {
+			return nil
+		}
+		failed := false
+		for _, name := range ready {
+			if err := this.JobStore.AddJob(this.workflowNodeJob(workflow, run, name)); err != nil {
+				// 无法保存的节点视为执行失败，按失败条件继续执行下游节点
+				log.Println("Error:", run.Id, name, err)
+				run.Complete(name, err)
+				failed = true
+			}
+		}
+		this.Wakeup()
+		if !failed {
+			return nil
+		}
+	}
+}
+
+// resumeWorkflowRuns 调度器启动时继续未结束的工作流运行: 重新执行停止时正在执行(已不在 store 中)的节点，
+// 并执行上游已结束的节点
+func (this *baseScheduler) resumeWorkflowRuns() {
+	workflowMu.Lock()
+	defer workflowMu.Unlock()
+
+	// 仍在 store 中等待执行的节点及其重试
+	stored := make(map[string]bool)
+	for _, job := range this.JobStore.GetAllJobs() {
+		if job.WorkflowRunId != "" && !job.Finished() {
+			stored[job.WorkflowRunId+"/"+job.WorkflowNode] = true
+		}
+	}
+	for _, run := range this.JobStore.GetWorkflowRuns("") {
+		if run.Finished() {
+			continue
+		}
+		run := run
+		workflow := this.JobStore.GetWorkflow(run.WorkflowId)
+		if workflow == nil {
+			// 工作流已被删除，无法继续执行
+			run.Cancel()
+			if err := this.JobStore.SaveWorkflowRun(run); err != nil {
+				log.Println("Error:", run.Id, err)
+			}
+			continue
+		}
+		for name, nodeRun := range run.Nodes {
+			if nodeRun.Status != jobs.WorkflowRunning || stored[run.Id+"/"+name] {
+				continue
+			}
+			log.Println("Resuming workflow run", run.Id, "node", name)
+			if err := this.JobStore.AddJob(this.workflowNodeJob(workflow, &run, name)); err != nil {
+				log.Println("Error:", run.Id, name, err)
+				run.Complete(name, err)
+			}
+		}
+		if err := this.dispatchWorkflow(workflow, &run); err != nil {
+			log.Println("Error:", run.Id, err)
+		}
+	}
+}
+
+// workflowNodeJob 构造执行工作流节点的一次性任务，立即执行
+func (this *baseScheduler) workflowNodeJob(workflow *jobs.Workflow, run *jobs.WorkflowRun, name string) jobs.Job {
+	node, _ := workflow.Node(name)
+	now := time.Now()
+	job := jobs.Job{
+		Id:        run.Id + "/" + node.Name,
+		Name:      node.Name,
+		FuncName:  node.FuncName,
+		Args:      node.Args,
+		StartTime: now,
+		Type:      jobs.ExecutionOnce,
+		Trigger:   &jobs.TriggerSpec{Type: jobs.TriggerDate},
+		Status:    jobs.StatusScheduled,
+		// 达到最大实例数时排队等待，跳过会使运行无法结束
+		InstancePolicy: jobs.InstanceQueue,
+		WorkflowRunId:  run.Id,
+		WorkflowNode:   node.Name,
+	}
+	// 节点引用已有任务时按该任务的定义执行，包括任务类型、超时、重试策略及后续任务，调度相关的字段不复制
+	if node.JobId != "" {
+		if referenced := this.JobStore.GetJobById(node.JobId); referenced.Id != "" {
+			job.FuncName = referenced.FuncName
+			job.Args = referenced.Args
+			job.Kwargs = referenced.Kwargs
+			job.Kind = referenced.Kind
+			job.Shell = referenced.Shell
+			job.HTTP = referenced.HTTP
+			job.GRPC = referenced.GRPC
+			job.Timeout = referenced.Timeout
+			job.Retry = referenced.Retry
+			job.MaxInstances = referenced.MaxInstances
+			job.OnSuccess = referenced.OnSuccess
+			job.ResultArgs = referenced.ResultArgs
+			job.Queue = referenced.Queue
+			job.Labels = referenced.Labels
+			// 原任务 id 为被引用的任务，与其共用实例数，并随其一起取消
+			job.RetryOf = referenced.Id
+		}
+	}
+	job.SetScheduledTime(now)
+	return job
+}